@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+	"github.com/gonzalo-mangado/logging/log"
+)
+
+// Compliance-grade record of an action performed on a resource.
+// Audit records are always emitted, regardless of LOG_LEVEL.
+type Event struct {
+	Who      string
+	Action   string
+	Resource string
+	Outcome  string
+	Before   interface{}
+	After    interface{}
+	Tags     log.Tags
+}
+
+const (
+	SUCCESS = "success"
+	FAILURE = "failure"
+	DENIED  = "denied"
+)
+
+var sinks []log.Sink
+
+// Registers a sink for the audit stream. While no sink is registered audit
+// records are written to the standard log output.
+func AddSink(sink log.Sink) {
+	sinks = append(sinks, sink)
+}
+
+// Validates and emits an audit record
+func Log(event Event) error {
+	if err := event.Validate(); err != nil {
+		log.Error(err, "invalid_audit_event")
+		return err
+	}
+	record := event.record()
+	if len(sinks) == 0 {
+		log.Log(record)
+		return nil
+	}
+	var failed []string
+	for _, sink := range sinks {
+		if err := sink.Write(record); err != nil {
+			stderr.Logf("error", "Error writing audit record to sink %T: %s", sink, err)
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Error writing audit record: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Returns an error listing the missing mandatory fields, or rejecting an
+// outcome other than SUCCESS, FAILURE or DENIED
+func (event Event) Validate() error {
+	var missing []string
+	if event.Who == "" {
+		missing = append(missing, "who")
+	}
+	if event.Action == "" {
+		missing = append(missing, "action")
+	}
+	if event.Resource == "" {
+		missing = append(missing, "resource")
+	}
+	if event.Outcome == "" {
+		missing = append(missing, "outcome")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Audit event missing mandatory fields: %s", strings.Join(missing, ", "))
+	}
+	switch event.Outcome {
+	case SUCCESS, FAILURE, DENIED:
+	default:
+		return fmt.Errorf("Invalid audit outcome %q: must be %s, %s or %s", event.Outcome, SUCCESS, FAILURE, DENIED)
+	}
+	return nil
+}
+
+func (event Event) record() log.Tags {
+	record := log.Tags{}
+	for k, v := range event.Tags {
+		record[k] = v
+	}
+	record["level"] = "audit"
+	record["time"] = clock.Timestamp().Format(time.RFC3339Nano)
+	record["who"] = event.Who
+	record["action"] = event.Action
+	record["resource"] = event.Resource
+	record["outcome"] = event.Outcome
+	if event.Before != nil {
+		record["before"] = event.Before
+	}
+	if event.After != nil {
+		record["after"] = event.After
+	}
+	return record
+}
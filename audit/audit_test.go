@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/log"
+	"github.com/gonzalo-mangado/logging/logtest"
+)
+
+type recordingSink struct {
+	records []log.Tags
+}
+
+func (sink *recordingSink) Write(record log.Tags) error {
+	sink.records = append(sink.records, record)
+	return nil
+}
+
+// Replaces the audit sinks with a recording one until the test ends
+func useRecordingSink(t *testing.T) *recordingSink {
+	previous := sinks
+	sink := &recordingSink{}
+	sinks = []log.Sink{sink}
+	t.Cleanup(func() { sinks = previous })
+	return sink
+}
+
+func TestRecordTimeFollowsLocation(t *testing.T) {
+	sink := useRecordingSink(t)
+	defer logtest.UseFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)).Restore()
+	clock.SetLocation(time.FixedZone("UTC-3", -3*60*60))
+	defer clock.SetLocation(nil)
+
+	Log(Event{Who: "alice", Action: "delete", Resource: "order/1", Outcome: SUCCESS})
+	if len(sink.records) != 1 || sink.records[0]["time"] != "2024-05-01T09:00:00-03:00" {
+		t.Errorf("Expected the time in the configured timezone, got %v", sink.records)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		event Event
+		err   string
+	}{
+		{"valid", Event{Who: "alice", Action: "delete", Resource: "order/1", Outcome: DENIED}, ""},
+		{"missing fields", Event{Who: "alice", Outcome: FAILURE},
+			"Audit event missing mandatory fields: action, resource"},
+		{"unknown outcome", Event{Who: "alice", Action: "delete", Resource: "order/1", Outcome: "ok"},
+			`Invalid audit outcome "ok": must be success, failure or denied`},
+		{"uppercase outcome", Event{Who: "alice", Action: "delete", Resource: "order/1", Outcome: "SUCCESS"},
+			`Invalid audit outcome "SUCCESS": must be success, failure or denied`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.event.Validate()
+			if (err == nil && c.err != "") || (err != nil && err.Error() != c.err) {
+				t.Errorf("Expected error %q, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestLogRejectsInvalidOutcome(t *testing.T) {
+	sink := useRecordingSink(t)
+	if err := Log(Event{Who: "alice", Action: "delete", Resource: "order/1", Outcome: "maybe"}); err == nil {
+		t.Errorf("Expected an error")
+	}
+	if len(sink.records) != 0 {
+		t.Errorf("Expected no audit record, got %v", sink.records)
+	}
+}
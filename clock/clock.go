@@ -0,0 +1,96 @@
+package clock
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Source of time for timestamps, durations and timers, replaceable in tests
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Holds a clockHolder, so tests can replace the clock while sinks have timers
+// pending, and Now reads the clock and its corrections at once
+var current atomic.Value
+
+// Serializes the changes of current
+var currentLock sync.Mutex
+
+type clockHolder struct {
+	Clock
+	offset   time.Duration  // Added to the times returned by Now
+	location *time.Location // Timezone of Timestamp
+}
+
+func init() {
+	current.Store(clockHolder{Clock: realClock{}, location: time.UTC})
+}
+
+func update(change func(holder *clockHolder)) {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+	holder := current.Load().(clockHolder)
+	change(&holder)
+	current.Store(holder)
+}
+
+// Replaces the clock used by the logging packages, nil restores the real one
+func Set(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	update(func(holder *clockHolder) { holder.Clock = c })
+}
+
+// Corrects the times returned by Now, and so the timestamps of records and
+// metrics, by d on hosts whose clock is known to be off by -d
+func SetOffset(d time.Duration) {
+	update(func(holder *clockHolder) { holder.offset = d })
+}
+
+// Sets the timezone of the timestamps written in records, UTC by default
+func SetLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	update(func(holder *clockHolder) { holder.location = loc })
+}
+
+func (holder clockHolder) now() time.Time {
+	return holder.Now().Add(holder.offset)
+}
+
+func Now() time.Time {
+	return current.Load().(clockHolder).now()
+}
+
+// Returns Now in the timezone set with SetLocation
+func Timestamp() time.Time {
+	holder := current.Load().(clockHolder)
+	return holder.now().In(holder.location)
+}
+
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+func Until(t time.Time) time.Duration {
+	return t.Sub(Now())
+}
+
+func After(d time.Duration) <-chan time.Time {
+	return current.Load().(clockHolder).After(d)
+}
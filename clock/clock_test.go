@@ -0,0 +1,69 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func (c fixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func TestOffsetAndLocation(t *testing.T) {
+	host := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("No timezone database")
+	}
+	cases := []struct {
+		offset    time.Duration
+		location  *time.Location
+		timestamp string
+	}{
+		{0, nil, "2024-05-01T12:00:00Z"},
+		{-1500 * time.Millisecond, nil, "2024-05-01T11:59:58.5Z"},
+		{time.Hour, newYork, "2024-05-01T09:00:00-04:00"},
+	}
+	Set(fixedClock{host})
+	defer Set(nil)
+	defer SetOffset(0)
+	defer SetLocation(nil)
+	for _, c := range cases {
+		SetOffset(c.offset)
+		SetLocation(c.location)
+		if now := Now(); !now.Equal(host.Add(c.offset)) {
+			t.Errorf("Offset %s: expected %s, got %s", c.offset, host.Add(c.offset), now)
+		}
+		if timestamp := Timestamp().Format(time.RFC3339Nano); timestamp != c.timestamp {
+			t.Errorf("Offset %s in %v: expected %s, got %s", c.offset, c.location, c.timestamp, timestamp)
+		}
+		if since := Since(host); since != c.offset {
+			t.Errorf("Offset %s: Since returned %s", c.offset, since)
+		}
+	}
+}
+
+func TestConcurrentCorrections(t *testing.T) {
+	defer SetOffset(0)
+	defer SetLocation(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			SetOffset(time.Duration(i) * time.Millisecond)
+			SetLocation(time.FixedZone("test", i))
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		Timestamp()
+	}
+	<-done
+}
@@ -0,0 +1,118 @@
+// Renders the output of the log package, read from stdin, as colorized and
+// aligned lines. Lines that are not records are printed untouched.
+//
+//	kubectl logs my-pod | logpretty -level warn -tag event=payment.failed
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gonzalo-mangado/logging/log"
+	"github.com/gonzalo-mangado/logging/logquery"
+)
+
+const (
+	reset  = "\033[0m"
+	gray   = "\033[90m"
+	green  = "\033[32m"
+	yellow = "\033[33m"
+	red    = "\033[31m"
+	bold   = "\033[1m"
+)
+
+var levelColors = map[int]string{
+	log.TRACE: gray,
+	log.DEBUG: gray,
+	log.INFO:  green,
+	log.WARN:  yellow,
+	log.ERROR: red,
+}
+
+func main() {
+	minLevel := flag.String("level", "trace", "minimum level shown")
+	noColor := flag.Bool("no-color", false, "disable colors")
+	width := flag.Int("width", 50, "width the messages are padded to")
+	tags := logquery.TagFlag{}
+	flag.Var(tags, "tag", "only show records with this key=value tag (repeatable)")
+	flag.Parse()
+
+	level, err := log.ParseLevel(*minLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for scanner.Scan() {
+		line := scanner.Text()
+		record, err := log.ParseLine(line)
+		if err != nil {
+			if level <= log.TRACE && len(tags) == 0 {
+				fmt.Fprintln(out, line)
+			}
+			continue
+		}
+		recordLevel, err := log.ParseLevel(fmt.Sprintf("%v", record["level"]))
+		match, _ := logquery.Query{Tags: tags}.Match(record)
+		if err == nil && recordLevel < level || !match {
+			continue
+		}
+		fmt.Fprintln(out, render(record, recordLevel, *width, !*noColor))
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// LEVEL  message                        key=value key=value
+func render(record log.Tags, level int, width int, color bool) string {
+	var line strings.Builder
+	levelName := strings.ToUpper(fmt.Sprintf("%v", record["level"]))
+	message := fmt.Sprintf("%v", record["message"])
+	if color {
+		line.WriteString(levelColor(level) + bold)
+	}
+	fmt.Fprintf(&line, "%-6s", levelName)
+	if color {
+		line.WriteString(reset)
+	}
+	fmt.Fprintf(&line, " %-*s", width, message)
+
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		if k != "level" && k != "message" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		line.WriteByte(' ')
+		if color {
+			line.WriteString(gray + k + "=" + reset)
+		} else {
+			line.WriteString(k + "=")
+		}
+		fmt.Fprintf(&line, "%v", record[k])
+	}
+	return line.String()
+}
+
+// Levels between the named ones take the color of the one below
+func levelColor(level int) string {
+	color := gray
+	for _, l := range []int{log.TRACE, log.DEBUG, log.INFO, log.WARN, log.ERROR} {
+		if level >= l {
+			color = levelColors[l]
+		}
+	}
+	return color
+}
@@ -0,0 +1,73 @@
+// Prints, as JSON lines, the records of log files (or stdin) matching a query.
+//
+//	logquery -level error -tag event=payment.failed -since 2h app.log
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/log"
+	"github.com/gonzalo-mangado/logging/logquery"
+)
+
+func main() {
+	q := logquery.Query{Tags: logquery.TagFlag{}}
+	flag.StringVar(&q.MinLevel, "level", "", "minimum level")
+	flag.Var(logquery.TagFlag(q.Tags), "tag", "only records with this key=value tag (repeatable)")
+	since := flag.String("since", "", "RFC3339 time or duration ago, e.g. 2h")
+	until := flag.String("until", "", "RFC3339 time or duration ago")
+	flag.StringVar(&q.TimeKey, "time-key", "", "tag holding the record time (default @timestamp, timestamp or time)")
+	flag.Parse()
+
+	var err error
+	if q.Since, err = parseTime(*since); err != nil {
+		fail(err)
+	}
+	if q.Until, err = parseTime(*until); err != nil {
+		fail(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	write := func(record log.Tags) error {
+		return encoder.Encode(record)
+	}
+	if flag.NArg() == 0 {
+		if err := logquery.Scan(os.Stdin, q, write); err != nil {
+			fail(err)
+		}
+		return
+	}
+	for _, path := range flag.Args() {
+		if err := scanFile(path, q, write); err != nil {
+			fail(err)
+		}
+	}
+}
+
+func scanFile(path string, q logquery.Query, fn func(log.Tags) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return logquery.Scan(file, q, fn)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
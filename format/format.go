@@ -1,9 +1,95 @@
 package format
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 )
 
 func Milliseconds(d time.Duration) float64 {
 	return d.Seconds() * 1e3
 }
+
+func Seconds(d time.Duration) float64 {
+	return d.Seconds()
+}
+
+func Micros(d time.Duration) float64 {
+	return d.Seconds() * 1e6
+}
+
+// Returns d with a single unit and at most one decimal: "350ms", "1.2s", "3.5m"
+func Duration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	switch {
+	case d < time.Microsecond:
+		return sign + strconv.FormatInt(int64(d), 10) + "ns"
+	case d < time.Millisecond:
+		return sign + decimal(Micros(d)) + "µs"
+	case d < time.Second:
+		return sign + decimal(Milliseconds(d)) + "ms"
+	case d < time.Minute:
+		return sign + decimal(d.Seconds()) + "s"
+	case d < time.Hour:
+		return sign + decimal(d.Minutes()) + "m"
+	}
+	return sign + decimal(d.Hours()) + "h"
+}
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// Returns n bytes in binary units: "512 B", "4.2 MiB"
+func Bytes(n int64) string {
+	if n < 1024 && n > -1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	value := float64(n) / 1024
+	unit := 0
+	for (value >= 1024 || value <= -1024) && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return decimal(value) + " " + byteUnits[unit]
+}
+
+// Returns the label of the bucket d falls in, given ascending upper bounds:
+// "<10ms", "10ms-50ms", ..., ">=1s". Labels form a fixed set for a given set of
+// bounds, so they are safe to use as metric tags.
+func Bucket(d time.Duration, bounds ...time.Duration) string {
+	for i, bound := range bounds {
+		if d < bound {
+			if i == 0 {
+				return "<" + Duration(bound)
+			}
+			return fmt.Sprintf("%s-%s", Duration(bounds[i-1]), Duration(bound))
+		}
+	}
+	if len(bounds) == 0 {
+		return "all"
+	}
+	return ">=" + Duration(bounds[len(bounds)-1])
+}
+
+// Upper bounds of the buckets used by LatencyBucket
+var LatencyBounds = []time.Duration{
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// Returns the LatencyBounds bucket d falls in: "<10ms", "10ms-50ms", ..., ">=10s".
+// There are 10 labels, so latencies can tag metrics without exploding cardinality.
+func LatencyBucket(d time.Duration) string {
+	return Bucket(d, LatencyBounds...)
+}
+
+// Formats with one decimal, dropping it when it is zero
+func decimal(value float64) string {
+	str := strconv.FormatFloat(value, 'f', 1, 64)
+	if len(str) > 2 && str[len(str)-2:] == ".0" {
+		return str[:len(str)-2]
+	}
+	return str
+}
@@ -0,0 +1,95 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	cases := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "0ns"},
+		{500 * time.Nanosecond, "500ns"},
+		{1500 * time.Nanosecond, "1.5µs"},
+		{350 * time.Millisecond, "350ms"},
+		{1234 * time.Millisecond, "1.2s"},
+		{90 * time.Second, "1.5m"},
+		{2 * time.Hour, "2h"},
+		{-350 * time.Millisecond, "-350ms"},
+	}
+	for _, c := range cases {
+		if actual := Duration(c.d); actual != c.expected {
+			t.Errorf("Duration(%d): expected %s, got %s", c.d, c.expected, actual)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	cases := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1023, "1023 B"},
+		{1024, "1 KiB"},
+		{1536, "1.5 KiB"},
+		{4404019, "4.2 MiB"},
+		{-2048, "-2 KiB"},
+		{1 << 62, "4 EiB"},
+	}
+	for _, c := range cases {
+		if actual := Bytes(c.n); actual != c.expected {
+			t.Errorf("Bytes(%d): expected %s, got %s", c.n, c.expected, actual)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	bounds := []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, time.Second}
+	cases := []struct {
+		d        time.Duration
+		bounds   []time.Duration
+		expected string
+	}{
+		{5 * time.Millisecond, bounds, "<10ms"},
+		{10 * time.Millisecond, bounds, "10ms-50ms"},
+		{999 * time.Millisecond, bounds, "50ms-1s"},
+		{time.Second, bounds, ">=1s"},
+		{time.Hour, nil, "all"},
+	}
+	for _, c := range cases {
+		if actual := Bucket(c.d, c.bounds...); actual != c.expected {
+			t.Errorf("Bucket(%s): expected %s, got %s", c.d, c.expected, actual)
+		}
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	cases := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "<10ms"},
+		{9 * time.Millisecond, "<10ms"},
+		{10 * time.Millisecond, "10ms-50ms"},
+		{120 * time.Millisecond, "100ms-250ms"},
+		{time.Second, "1s-2.5s"},
+		{9 * time.Second, "5s-10s"},
+		{time.Minute, ">=10s"},
+	}
+	for _, c := range cases {
+		if label := LatencyBucket(c.d); label != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.d, c.expected, label)
+		}
+	}
+	labels := map[string]bool{}
+	for d := time.Duration(0); d < 20*time.Second; d += time.Millisecond {
+		labels[LatencyBucket(d)] = true
+	}
+	if len(labels) != len(LatencyBounds)+1 {
+		t.Errorf("Expected %d labels, got %d", len(LatencyBounds)+1, len(labels))
+	}
+}
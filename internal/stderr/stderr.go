@@ -0,0 +1,13 @@
+// Package stderr writes the diagnostics of the logging packages that cannot go
+// through the logger itself, in the brackets format of the default formatter.
+package stderr
+
+import (
+	"fmt"
+	"os"
+)
+
+// Writes [level:<level>][message:<formatted message>] to the standard error
+func Logf(level string, format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "[level:%s][message:%s]\n", level, fmt.Sprintf(format, a...))
+}
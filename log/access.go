@@ -0,0 +1,173 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/format"
+)
+
+// Formats of the access log middleware
+const (
+	ACCESS_TAGS     = "tags"     // Info records with the request attributes as tags
+	ACCESS_COMMON   = "common"   // Apache Common Log Format
+	ACCESS_COMBINED = "combined" // Apache Combined Log Format
+)
+
+// Destination of the common and combined access log lines
+var AccessLogOutput io.Writer = os.Stdout
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// Request headers added to the ACCESS_TAGS records as "header.<name>" tags.
+// Values of the RedactedHeaders are replaced with "[REDACTED]".
+var AccessLogHeaders []string
+
+// Size after which the captured header values are truncated
+var AccessHeaderMaxSize = 256
+
+type AccessEntry struct {
+	RemoteAddr string
+	User       string
+	Method     string
+	URI        string
+	Proto      string
+	Status     int
+	Size       int
+	Time       time.Time
+	Duration   time.Duration
+	Referer    string
+	UserAgent  string
+	Headers    map[string]string // Captured AccessLogHeaders, by lowercase name
+}
+
+// Middleware logging every request in the given format
+func AccessLog(logFormat string) gin.HandlerFunc {
+	switch logFormat {
+	case ACCESS_TAGS, ACCESS_COMMON, ACCESS_COMBINED:
+	default:
+		panic(fmt.Sprintf("Invalid access log format: %s", logFormat))
+	}
+	return func(c *gin.Context) {
+		start := clock.Now()
+		c.Next()
+		entry := AccessEntry{
+			RemoteAddr: c.ClientIP(),
+			Method:     c.Request.Method,
+			URI:        c.Request.URL.RequestURI(),
+			Proto:      c.Request.Proto,
+			Status:     c.Writer.Status(),
+			Size:       c.Writer.Size(),
+			Time:       start,
+			Duration:   clock.Since(start),
+			Referer:    c.Request.Referer(),
+			UserAgent:  c.Request.UserAgent(),
+		}
+		if user, _, ok := c.Request.BasicAuth(); ok {
+			entry.User = user
+		}
+		if len(AccessLogHeaders) > 0 && logFormat == ACCESS_TAGS {
+			entry.Headers = captureHeaders(c.Request.Header, AccessLogHeaders, AccessHeaderMaxSize)
+		}
+		switch logFormat {
+		case ACCESS_COMMON:
+			fmt.Fprintln(AccessLogOutput, entry.Common())
+		case ACCESS_COMBINED:
+			fmt.Fprintln(AccessLogOutput, entry.Combined())
+		default:
+			Info(fmt.Sprintf("%s %s", entry.Method, entry.URI), "access", entry.Tags())
+		}
+	}
+}
+
+// Apache Common Log Format: host ident user [time] "request" status size
+func (entry AccessEntry) Common() string {
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		clfField(entry.RemoteAddr), clfField(entry.User), entry.Time.Format(clfTimeFormat),
+		clfEscape(entry.Method), clfEscape(entry.URI), clfEscape(entry.Proto), entry.Status, clfSize(entry.Size))
+}
+
+// Apache Combined Log Format: Common Log Format followed by "referer" "user agent"
+func (entry AccessEntry) Combined() string {
+	return fmt.Sprintf(`%s "%s" "%s"`, entry.Common(), clfField(entry.Referer), clfField(entry.UserAgent))
+}
+
+func (entry AccessEntry) Tags() Tags {
+	tags := Tags{
+		"remote_addr": entry.RemoteAddr,
+		"method":      entry.Method,
+		"uri":         entry.URI,
+		"status":      entry.Status,
+		"size":        entry.Size,
+		"duration_ms": format.Milliseconds(entry.Duration),
+		"user_agent":  entry.UserAgent,
+	}
+	for name, value := range entry.Headers {
+		tags["header."+name] = value
+	}
+	return tags
+}
+
+// Returns the values of the allowed headers present in header, redacted and
+// truncated to maxSize
+func captureHeaders(header http.Header, allowed []string, maxSize int) map[string]string {
+	redacted := redactHeaders(header)
+	captured := map[string]string{}
+	for _, name := range allowed {
+		values, ok := redacted[http.CanonicalHeaderKey(name)]
+		if !ok {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if len(value) > maxSize {
+			value = fmt.Sprintf("%s...(%d bytes)", value[:maxSize], len(value))
+		}
+		captured[strings.ToLower(name)] = value
+	}
+	return captured
+}
+
+func clfField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return clfEscape(value)
+}
+
+// Escapes quotes, backslashes and non-printable bytes as Apache does, so a
+// client cannot forge fields by sending them in the request
+func clfEscape(value string) string {
+	var buf strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&buf, `\x%02x`, c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+func clfSize(size int) string {
+	if size <= 0 {
+		return "-"
+	}
+	return strconv.Itoa(size)
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	header.Add("Accept-Language", "en")
+	header.Add("Accept-Language", "es")
+	header.Set("Authorization", "Bearer secret")
+	header.Set("X-Long", strings.Repeat("a", 30))
+	cases := []struct {
+		name     string
+		allowed  []string
+		expected map[string]string
+	}{
+		{"allowlist", []string{"accept", "X-Missing"}, map[string]string{"accept": "application/json"}},
+		{"several values", []string{"Accept-Language"}, map[string]string{"accept-language": "en, es"}},
+		{"redacted", []string{"Authorization"}, map[string]string{"authorization": "[REDACTED]"}},
+		{"truncated", []string{"X-Long"}, map[string]string{"x-long": strings.Repeat("a", 20) + "...(30 bytes)"}},
+	}
+	for _, c := range cases {
+		if captured := captureHeaders(header, c.allowed, 20); !reflect.DeepEqual(captured, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, captured)
+		}
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Error("The request header was redacted")
+	}
+}
+
+func TestAccessEntryHeaderTags(t *testing.T) {
+	tags := AccessEntry{Headers: map[string]string{"accept": "text/html"}}.Tags()
+	if tags["header.accept"] != "text/html" {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+}
+
+func TestAccessEntryEscapesCombined(t *testing.T) {
+	entry := AccessEntry{
+		RemoteAddr: "10.0.0.1",
+		Time:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Method:     "GET",
+		URI:        `/search?q="x"`,
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Size:       512,
+		Referer:    `https://example.com/\`,
+		UserAgent:  "curl\" 200 1\n",
+	}
+	expected := `10.0.0.1 - - [01/May/2024:12:00:00 +0000] "GET /search?q=\"x\" HTTP/1.1" 200 512 "https://example.com/\\" "curl\" 200 1\n"`
+	if combined := entry.Combined(); combined != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, combined)
+	}
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Calls its callback when more than maxErrors error or critic records are
+// logged within window, and again only after the rate went back below it
+type errorRateAlert struct {
+	lock      sync.Mutex
+	maxErrors int
+	window    time.Duration
+	times     []time.Time
+	triggered bool
+	callback  func(count int, window time.Duration)
+}
+
+var errorAlerts []*errorRateAlert
+var errorAlertsLock sync.RWMutex
+
+// Registers a callback invoked when more than maxErrors error or critic records
+// are logged within the sliding window, e.g. to open a circuit breaker or page
+// someone. A "logging.error_rate_alert" counter is also pushed. The alert
+// re-arms once the rate falls back to maxErrors or less.
+func AlertOnErrorRate(maxErrors int, window time.Duration, callback func(count int, window time.Duration)) {
+	errorAlertsLock.Lock()
+	defer errorAlertsLock.Unlock()
+	errorAlerts = append(errorAlerts, &errorRateAlert{maxErrors: maxErrors, window: window, callback: callback})
+}
+
+// Removes the alerts registered with AlertOnErrorRate
+func ClearErrorRateAlerts() {
+	errorAlertsLock.Lock()
+	defer errorAlertsLock.Unlock()
+	errorAlerts = nil
+}
+
+func countError() {
+	errorAlertsLock.RLock()
+	alerts := errorAlerts
+	errorAlertsLock.RUnlock()
+	now := clock.Now()
+	for _, alert := range alerts {
+		if count, fire := alert.add(now); fire {
+			if pushMetrics {
+				metrics.PushMetric(metrics.Simple("logging.error_rate_alert", 1).Values[0], nil, metrics.Tags{"window": alert.window.String()})
+			}
+			alert.callback(count, alert.window)
+		}
+	}
+}
+
+// Returns the errors within the window and whether the alert has just triggered
+func (alert *errorRateAlert) add(now time.Time) (int, bool) {
+	alert.lock.Lock()
+	defer alert.lock.Unlock()
+	alert.times = append(alert.times, now)
+	start := 0
+	for start < len(alert.times) && now.Sub(alert.times[start]) > alert.window {
+		start++
+	}
+	alert.times = alert.times[start:]
+	// Only the last maxErrors+1 times are needed to know whether the rate is exceeded
+	if len(alert.times) > alert.maxErrors+1 {
+		alert.times = alert.times[len(alert.times)-alert.maxErrors-1:]
+	}
+	count := len(alert.times)
+	if count <= alert.maxErrors {
+		alert.triggered = false
+		return count, false
+	}
+	if alert.triggered {
+		return count, false
+	}
+	alert.triggered = true
+	return count, true
+}
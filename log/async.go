@@ -0,0 +1,203 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Overflow policies of AsyncSink
+const (
+	BLOCK       = "block"       // Wait for room in the queue
+	DROP_NEWEST = "drop_newest" // Discard the record being written
+	DROP_OLDEST = "drop_oldest" // Discard the oldest queued record
+)
+
+// Sink writing records to another sink from a goroutine, through a bounded queue.
+// Dropped records are counted by level and reported every summary interval with
+// a warn record and a "logging.dropped" metric.
+type AsyncSink struct {
+	sink    Sink
+	queue   chan Tags
+	policy  string
+	lock    sync.Mutex
+	drained *sync.Cond
+	pending int
+	dropped map[string]int
+	total   int
+	shut    bool // Set by Close
+	stop    chan struct{}
+	closed  chan struct{}
+}
+
+func NewAsyncSink(sink Sink, size int, policy string, summaryInterval time.Duration) *AsyncSink {
+	switch policy {
+	case BLOCK, DROP_NEWEST, DROP_OLDEST:
+	default:
+		panic(fmt.Sprintf("Invalid overflow policy: %s", policy))
+	}
+	async := &AsyncSink{
+		sink:    sink,
+		queue:   make(chan Tags, size),
+		policy:  policy,
+		dropped: map[string]int{},
+		stop:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	async.drained = sync.NewCond(&async.lock)
+	go async.run()
+	if summaryInterval > 0 {
+		go async.summarizeEvery(summaryInterval)
+	}
+	return async
+}
+
+func (async *AsyncSink) Write(record Tags) error {
+	async.lock.Lock()
+	if async.shut {
+		async.lock.Unlock()
+		return fmt.Errorf("Sink closed")
+	}
+	async.pending++
+	async.lock.Unlock()
+	switch async.policy {
+	case BLOCK:
+		async.queue <- record
+		return nil
+	case DROP_OLDEST:
+		for {
+			select {
+			case async.queue <- record:
+				return nil
+			default:
+			}
+			select {
+			case oldest := <-async.queue:
+				async.drop(oldest)
+			default:
+			}
+		}
+	default:
+		select {
+		case async.queue <- record:
+		default:
+			async.drop(record)
+		}
+		return nil
+	}
+}
+
+// Waits until the queued records are written and flushes the wrapped sink
+func (async *AsyncSink) Flush() error {
+	async.lock.Lock()
+	for async.pending > 0 {
+		async.drained.Wait()
+	}
+	async.lock.Unlock()
+	if f, ok := async.sink.(sinkFlusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Writes the queued records, reports the pending drops and stops the goroutines
+func (async *AsyncSink) Close() error {
+	async.lock.Lock()
+	if async.shut {
+		async.lock.Unlock()
+		return nil
+	}
+	async.shut = true
+	async.lock.Unlock()
+	async.summarize()
+	err := async.Flush()
+	close(async.stop)
+	<-async.closed
+	return err
+}
+
+// Returns the number of records dropped so far, by level
+func (async *AsyncSink) Dropped() map[string]int {
+	async.lock.Lock()
+	defer async.lock.Unlock()
+	dropped := make(map[string]int, len(async.dropped))
+	for level, count := range async.dropped {
+		dropped[level] = count
+	}
+	return dropped
+}
+
+// Returns the queue depth and the number of records dropped since creation
+func (async *AsyncSink) Status() Tags {
+	async.lock.Lock()
+	defer async.lock.Unlock()
+	return Tags{"queued": async.pending, "capacity": cap(async.queue), "policy": async.policy, "dropped": async.total}
+}
+
+func (async *AsyncSink) run() {
+	defer close(async.closed)
+	for {
+		select {
+		case record := <-async.queue:
+			if err := async.sink.Write(record); err != nil {
+				writeSinkError(async.sink, err)
+			}
+			async.done()
+		case <-async.stop:
+			return
+		}
+	}
+}
+
+func (async *AsyncSink) drop(record Tags) {
+	async.lock.Lock()
+	async.dropped[fmt.Sprintf("%v", record["level"])]++
+	async.total++
+	async.lock.Unlock()
+	async.done()
+}
+
+func (async *AsyncSink) done() {
+	async.lock.Lock()
+	async.pending--
+	if async.pending == 0 {
+		async.drained.Broadcast()
+	}
+	async.lock.Unlock()
+}
+
+func (async *AsyncSink) summarizeEvery(interval time.Duration) {
+	for {
+		select {
+		case <-async.stop:
+			return
+		case <-clock.After(interval):
+			async.summarize()
+		}
+	}
+}
+
+// Reports and resets the drop counts
+func (async *AsyncSink) summarize() {
+	async.lock.Lock()
+	dropped := async.dropped
+	async.dropped = map[string]int{}
+	async.lock.Unlock()
+	total := 0
+	tags := Tags{}
+	for level, count := range dropped {
+		total += count
+		tags["dropped."+level] = count
+		if pushMetrics {
+			metrics.PushMetric(metrics.Simple("logging.dropped", float64(count)).Values[0], nil, metrics.Tags{"level": level, "sink": fmt.Sprintf("%T", async.sink)})
+		}
+	}
+	if total > 0 {
+		tags["dropped"] = total
+		tags["sink"] = fmt.Sprintf("%T", async.sink)
+		defaultContext.Log("warn", fmt.Sprintf("Dropped %d records", total), "logging.dropped", tags)
+	}
+}
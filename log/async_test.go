@@ -0,0 +1,101 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Sink blocking its writes until released
+type gatedSink struct {
+	lock    sync.Mutex
+	gate    chan struct{}
+	started chan struct{}
+	records []string
+}
+
+func newGatedSink() *gatedSink {
+	return &gatedSink{gate: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (sink *gatedSink) Write(record Tags) error {
+	sink.started <- struct{}{}
+	<-sink.gate
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	sink.records = append(sink.records, fmt.Sprintf("%v", record["message"]))
+	return nil
+}
+
+func TestAsyncSinkPolicies(t *testing.T) {
+	cases := []struct {
+		policy   string
+		expected string
+		dropped  int
+	}{
+		{DROP_NEWEST, "[a b c]", 2},
+		{DROP_OLDEST, "[a d e]", 2},
+	}
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			withLevel(t, NONE)
+			inner := newGatedSink()
+			async := NewAsyncSink(inner, 2, c.policy, 0)
+			async.Write(Tags{"message": "a", "level": "info"})
+			// a is being written, the queue holds 2 more records
+			<-inner.started
+			for _, message := range []string{"b", "c", "d", "e"} {
+				async.Write(Tags{"message": message, "level": "info"})
+			}
+			close(inner.gate)
+			if err := async.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if records := fmt.Sprintf("%v", inner.records); records != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, records)
+			}
+			if dropped := async.Status()["dropped"]; dropped != c.dropped {
+				t.Errorf("Expected %d dropped, got %v", c.dropped, dropped)
+			}
+		})
+	}
+}
+
+func TestAsyncSinkBlocks(t *testing.T) {
+	inner := newGatedSink()
+	async := NewAsyncSink(inner, 1, BLOCK, 0)
+	async.Write(Tags{"message": "a"})
+	<-inner.started
+	async.Write(Tags{"message": "b"})
+	written := make(chan struct{})
+	go func() {
+		async.Write(Tags{"message": "c"})
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("Write did not block with a full queue")
+	default:
+	}
+	close(inner.gate)
+	<-written
+	async.Close()
+	if records := fmt.Sprintf("%v", inner.records); records != "[a b c]" {
+		t.Errorf("Unexpected records %s", records)
+	}
+}
+
+func TestAsyncSinkClose(t *testing.T) {
+	inner := newGatedSink()
+	close(inner.gate)
+	async := NewAsyncSink(inner, 1, BLOCK, 0)
+	if err := async.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := async.Close(); err != nil {
+		t.Errorf("Second Close failed: %s", err)
+	}
+	if err := async.Write(Tags{"message": "late"}); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+var azureLogType = regexp.MustCompile(`^[A-Za-z0-9_]{1,100}$`)
+
+// Configuration of AzureSink
+type AzureSinkConfig struct {
+	WorkspaceID string
+	// Primary or secondary key of the workspace, base64 encoded as shown by the portal
+	SharedKey string
+	// Custom log table the records are stored in, with the "_CL" suffix added by Azure
+	LogType string
+	// Batching, queueing and retries. URL, Encode and Sign are set by the sink.
+	HTTP HTTPSinkConfig
+}
+
+// Sink sending records to an Azure Monitor Log Analytics workspace through the
+// HTTP Data Collector API, so Azure hosted services need no agent. The time each
+// record was logged is sent as its TimeGenerated.
+type AzureSink struct {
+	*HTTPSink
+}
+
+func NewAzureSink(config AzureSinkConfig) (*AzureSink, error) {
+	if config.WorkspaceID == "" {
+		return nil, fmt.Errorf("Missing Azure workspace ID")
+	}
+	key, err := base64.StdEncoding.DecodeString(config.SharedKey)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("Invalid Azure shared key: must be base64")
+	}
+	if !azureLogType.MatchString(config.LogType) {
+		return nil, fmt.Errorf("Invalid Azure log type %q: must be up to 100 letters, digits or underscores", config.LogType)
+	}
+	httpConfig := config.HTTP
+	httpConfig.URL = fmt.Sprintf("https://%s.ods.opinsights.azure.com/api/logs?api-version=2016-04-01", config.WorkspaceID)
+	httpConfig.Encode = jsonArrayEncoder
+	httpConfig.ContentType = "application/json"
+	// The Data Collector API does not accept compressed bodies
+	httpConfig.DisableCompression = true
+	httpConfig.Sign = azureSigner(config.WorkspaceID, key, config.LogType)
+	return &AzureSink{NewHTTPSink(httpConfig)}, nil
+}
+
+func (sink *AzureSink) Write(record Tags) error {
+	return sink.HTTPSink.Write(stamped(record, "timestamp", clock.Now().UTC().Format(time.RFC3339Nano)))
+}
+
+// Returns a function adding the Data Collector API headers, signed with key
+func azureSigner(workspaceID string, key []byte, logType string) func(req *http.Request, body []byte) error {
+	return func(req *http.Request, body []byte) error {
+		date := clock.Now().UTC().Format(http.TimeFormat)
+		req.Header.Set("Log-Type", logType)
+		req.Header.Set("x-ms-date", date)
+		req.Header.Set("time-generated-field", "timestamp")
+		req.Header.Set("Authorization", "SharedKey "+workspaceID+":"+azureSignature(key, len(body), date))
+		return nil
+	}
+}
+
+func azureSignature(key []byte, contentLength int, date string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("POST\n" + strconv.Itoa(contentLength) + "\napplication/json\nx-ms-date:" + date + "\n/api/logs"))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
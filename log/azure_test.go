@@ -0,0 +1,55 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewAzureSinkValidates(t *testing.T) {
+	cases := []struct {
+		config AzureSinkConfig
+		err    string
+	}{
+		{AzureSinkConfig{SharedKey: "c2VjcmV0", LogType: "App"}, "workspace ID"},
+		{AzureSinkConfig{WorkspaceID: "ws", SharedKey: "not base64!", LogType: "App"}, "shared key"},
+		{AzureSinkConfig{WorkspaceID: "ws", SharedKey: "c2VjcmV0", LogType: "App-Logs"}, "log type"},
+		{AzureSinkConfig{WorkspaceID: "ws", SharedKey: "c2VjcmV0", LogType: ""}, "log type"},
+		{AzureSinkConfig{WorkspaceID: "ws", SharedKey: "c2VjcmV0", LogType: "App_Logs"}, ""},
+	}
+	for _, c := range cases {
+		sink, err := NewAzureSink(c.config)
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error %s", c.config, err)
+				continue
+			}
+			sink.Close()
+		} else if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%+v: expected an error about %s, got %v", c.config, c.err, err)
+		}
+	}
+}
+
+func TestAzureSignature(t *testing.T) {
+	// Computed independently from the Data Collector API documentation
+	expected := "UoQePv5YNwDZ8tbXtIN7+VhOr9P+i6pX+Jxuu2XoXKs="
+	if signature := azureSignature([]byte("secret-key"), 42, "Mon, 02 Jan 2006 15:04:05 GMT"); signature != expected {
+		t.Errorf("Expected %s, got %s", expected, signature)
+	}
+}
+
+func TestJSONArrayEncoder(t *testing.T) {
+	cases := []struct {
+		records  []Tags
+		expected string
+	}{
+		{nil, `[]`},
+		{[]Tags{{"message": "a"}}, `[{"message":"a"}]`},
+		{[]Tags{{"message": "a"}, {"message": "b"}}, `[{"message":"a"},{"message":"b"}]`},
+	}
+	for _, c := range cases {
+		if body, _ := jsonArrayEncoder(c.records); string(body) != c.expected {
+			t.Errorf("Expected %s, got %s", c.expected, body)
+		}
+	}
+}
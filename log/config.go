@@ -0,0 +1,96 @@
+package log
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Returns the effective configuration, whether it came from the environment or
+// from code, for logging at startup or when debugging a misconfiguration
+func DumpConfig() Tags {
+	namedLevelsLock.RLock()
+	named := make(Tags, len(namedLevels))
+	for name, level := range namedLevels {
+		named[name] = levelName(level)
+	}
+	namedLevelsLock.RUnlock()
+	propagatedTagsLock.RLock()
+	propagated := append([]string(nil), propagatedTags...)
+	propagatedTagsLock.RUnlock()
+	filtersLock.RLock()
+	filterCount := len(filters)
+	filtersLock.RUnlock()
+	sinksLock.RLock()
+	sinkTypes := make([]string, len(sinks))
+	for i, sink := range sinks {
+		sinkTypes[i] = fmt.Sprintf("%T", sink)
+	}
+	sinksLock.RUnlock()
+	reporters := registeredReporters()
+	reporterTypes := make([]string, len(reporters))
+	for i, reporter := range reporters {
+		reporterTypes[i] = fmt.Sprintf("%T", reporter)
+	}
+	return Tags{
+		"level":            levelName(Level),
+		"named_levels":     named,
+		"formatter":        fmt.Sprintf("%T", formatter),
+		"output":           outputName(output),
+		"sampling":         samplingRate,
+		"global_tags":      globalFields.tags(),
+		"event_strictness": eventStrictness,
+		"filters":          filterCount,
+		"policies":         len(Policies()),
+		"sinks":            sinkTypes,
+		"error_reporters":  reporterTypes,
+		"signed":           currentSigner() != nil,
+		"push_metrics":     pushMetrics,
+		"propagated_tags":  propagated,
+		"metrics":          metrics.Status(),
+	}
+}
+
+// Returns the settings that contradict each other or make the logger drop
+// everything, or nil if there are none
+func Validate() []error {
+	var errs []error
+	status := metrics.Status()
+	if pushMetrics && status["prefix"] == "" {
+		errs = append(errs, fmt.Errorf("Metrics are pushed without a prefix: call PushMetrics with the application name"))
+	}
+	if status["dry_run"] == true && !pushMetrics {
+		errs = append(errs, fmt.Errorf("Metrics dry run is enabled but PushMetrics was not called, so no metric is logged"))
+	}
+	if Level >= NONE {
+		sinksLock.RLock()
+		sinkCount := len(sinks)
+		sinksLock.RUnlock()
+		if sinkCount > 0 || len(registeredReporters()) > 0 {
+			errs = append(errs, fmt.Errorf("Level is NONE: sinks and error reporters are registered but receive nothing"))
+		}
+	}
+	if samplingRate <= 0 {
+		errs = append(errs, fmt.Errorf("Sampling is 0: every trace, debug, info and metric record is dropped"))
+	}
+	if eventStrictness != EVENTS_LAX {
+		eventSchemasLock.RLock()
+		schemaCount := len(eventSchemas)
+		eventSchemasLock.RUnlock()
+		if schemaCount == 0 {
+			errs = append(errs, fmt.Errorf("Event strictness is %d but no event is registered", eventStrictness))
+		}
+	}
+	if output == nil {
+		errs = append(errs, fmt.Errorf("Output is nil"))
+	}
+	return errs
+}
+
+func outputName(w interface{}) string {
+	if file, ok := w.(*os.File); ok {
+		return file.Name()
+	}
+	return fmt.Sprintf("%T", w)
+}
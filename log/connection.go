@@ -0,0 +1,155 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/format"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+var openConnections = map[string]int{}
+var openConnectionsLock sync.Mutex
+
+// Interval between pushes of the "logging.connections.open" gauges and of the
+// messages counted in "logging.connection.messages" since the previous push.
+// Set it before opening the first connection.
+var ConnectionMetricsInterval = 10 * time.Second
+
+type connectionMessagesKey struct {
+	kind      string
+	direction string
+}
+
+// Messages counted since the previous push, by kind and direction
+var connectionMessages sync.Map // connectionMessagesKey to *int64
+var connectionMetricsOnce sync.Once
+
+// Long-lived connection (websocket, server-sent events, ...) whose records are
+// tagged with its "connection.id" and "connection.kind"
+type Connection struct {
+	logContext
+	kind        string
+	start       time.Time
+	messagesIn  int64
+	messagesOut int64
+	bytesIn     int64
+	bytesOut    int64
+	closed      int32
+}
+
+// Logs the opening of a connection of the given kind and counts it in the
+// "logging.connections.open" gauge until Close is called. The gauge and the
+// message counts are pushed every ConnectionMetricsInterval.
+func (context logContext) OpenConnection(kind string) *Connection {
+	connectionMetricsOnce.Do(func() {
+		go pushConnectionMetricsEvery(ConnectionMetricsInterval)
+	})
+	conn := &Connection{
+		logContext: context.WithContext(Tags{"connection.id": randomID(), "connection.kind": kind}),
+		kind:       kind,
+		start:      clock.Now(),
+	}
+	updateOpenConnections(kind, 1)
+	conn.Info("Connection opened", "connection.opened")
+	return conn
+}
+
+// Like logContext.OpenConnection, for the default context
+func OpenConnection(kind string) *Connection {
+	return defaultContext.OpenConnection(kind)
+}
+
+// Returns the number of connections open, by kind
+func OpenConnections() map[string]int {
+	openConnectionsLock.Lock()
+	defer openConnectionsLock.Unlock()
+	open := make(map[string]int, len(openConnections))
+	for kind, n := range openConnections {
+		open[kind] = n
+	}
+	return open
+}
+
+func updateOpenConnections(kind string, delta int) {
+	openConnectionsLock.Lock()
+	defer openConnectionsLock.Unlock()
+	openConnections[kind] += delta
+}
+
+func pushConnectionMetricsEvery(interval time.Duration) {
+	for {
+		<-clock.After(interval)
+		pushConnectionMetrics()
+	}
+}
+
+// Pushes the open connections of every kind opened so far, and pushes and
+// resets the message counts
+func pushConnectionMetrics() {
+	if !pushMetrics {
+		return
+	}
+	for kind, n := range OpenConnections() {
+		metrics.PushMetric(metrics.Full("logging.connections.open", float64(n)).Values[0], nil, metrics.Tags{"kind": kind})
+	}
+	connectionMessages.Range(func(k, v interface{}) bool {
+		key := k.(connectionMessagesKey)
+		if n := atomic.SwapInt64(v.(*int64), 0); n > 0 {
+			metrics.PushMetric(metrics.Simple("logging.connection.messages", float64(n)).Values[0], nil, metrics.Tags{"kind": key.kind, "direction": key.direction})
+		}
+		return true
+	})
+}
+
+// Counts a message of size bytes received through the connection
+func (conn *Connection) Received(size int) {
+	atomic.AddInt64(&conn.messagesIn, 1)
+	atomic.AddInt64(&conn.bytesIn, int64(size))
+	conn.countMessage("in")
+}
+
+// Counts a message of size bytes sent through the connection
+func (conn *Connection) Sent(size int) {
+	atomic.AddInt64(&conn.messagesOut, 1)
+	atomic.AddInt64(&conn.bytesOut, int64(size))
+	conn.countMessage("out")
+}
+
+func (conn *Connection) countMessage(direction string) {
+	if !pushMetrics {
+		return
+	}
+	key := connectionMessagesKey{conn.kind, direction}
+	count, ok := connectionMessages.Load(key)
+	if !ok {
+		count, _ = connectionMessages.LoadOrStore(key, new(int64))
+	}
+	atomic.AddInt64(count.(*int64), 1)
+}
+
+// Logs the closing of the connection with its duration, the messages exchanged
+// and reason ("client_gone", "idle_timeout", ...). Closings caused by err are
+// logged as warnings. Calls after the first are ignored.
+func (conn *Connection) Close(reason string, err error) {
+	if !atomic.CompareAndSwapInt32(&conn.closed, 0, 1) {
+		return
+	}
+	updateOpenConnections(conn.kind, -1)
+	tags := Tags{
+		"reason":       reason,
+		"duration_ms":  format.Milliseconds(clock.Since(conn.start)),
+		"messages_in":  atomic.LoadInt64(&conn.messagesIn),
+		"messages_out": atomic.LoadInt64(&conn.messagesOut),
+		"bytes_in":     atomic.LoadInt64(&conn.bytesIn),
+		"bytes_out":    atomic.LoadInt64(&conn.bytesOut),
+	}
+	if err != nil {
+		tags["error"] = err.Error()
+		conn.Warn("Connection closed: "+reason, "connection.closed", tags)
+		return
+	}
+	conn.Info("Connection closed: "+reason, "connection.closed", tags)
+}
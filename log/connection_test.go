@@ -0,0 +1,80 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConnectionClose(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		level  string
+		closes int
+	}{
+		{"normal", nil, "info", 1},
+		{"error", errors.New("broken pipe"), "warn", 1},
+		{"closed twice", nil, "info", 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			context, sink := recordingContext()
+			conn := context.OpenConnection("websocket")
+			if open := OpenConnections()["websocket"]; open != 1 {
+				t.Errorf("Expected 1 open connection, got %d", open)
+			}
+			conn.Received(10)
+			conn.Sent(5)
+			conn.Sent(7)
+			for i := 0; i < c.closes; i++ {
+				conn.Close("client_gone", c.err)
+			}
+			if open := OpenConnections()["websocket"]; open != 0 {
+				t.Errorf("Expected no open connection, got %d", open)
+			}
+			if len(sink.records) != 2 {
+				t.Fatalf("Expected the opening and closing records, got %v", sink.records)
+			}
+			opened, closed := sink.records[0], sink.records[1]
+			if opened["connection.id"] == nil || opened["connection.id"] != closed["connection.id"] || closed["connection.kind"] != "websocket" {
+				t.Errorf("Unexpected connection tags %v, %v", opened, closed)
+			}
+			if closed["level"] != c.level || closed["messages_in"] != int64(1) || closed["messages_out"] != int64(2) ||
+				closed["bytes_out"] != int64(12) || closed["reason"] != "client_gone" {
+				t.Errorf("Unexpected closing record %v", closed)
+			}
+		})
+	}
+}
+
+func TestConnectionMetricsArePushedAggregated(t *testing.T) {
+	backend := usePushedMetrics(t)
+	pushConnectionMetrics() // Drops the counts of other tests
+	backend.reset()
+	context, _ := recordingContext()
+	conn := context.OpenConnection("sse")
+	defer conn.Close("done", nil)
+	conn.Received(1)
+	conn.Sent(1)
+	conn.Sent(1)
+	if pushed := backend.recorded(); len(pushed) != 0 {
+		t.Fatalf("Expected no metric pushed before the interval, got %v", pushed)
+	}
+	pushConnectionMetrics()
+	pushed := strings.Join(backend.recorded(), "\n")
+	for _, expected := range []string{
+		"logging.connections.open 1 kind:sse",
+		"logging.connection.messages 1 direction:in,kind:sse",
+		"logging.connection.messages 2 direction:out,kind:sse",
+	} {
+		if !strings.Contains(pushed, expected) {
+			t.Errorf("Expected %q in the pushed metrics:\n%s", expected, pushed)
+		}
+	}
+	backend.reset()
+	pushConnectionMetrics()
+	if pushed := strings.Join(backend.recorded(), "\n"); strings.Contains(pushed, "logging.connection.messages") {
+		t.Errorf("Expected the message counts to be reset, got:\n%s", pushed)
+	}
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/format"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+type contextKey struct{}
+
+// Returns the tags an application stores in its own context keys (user ID, tenant, locale, ...)
+type ContextExtractor func(ctx context.Context) Tags
+
+var contextExtractors []ContextExtractor
+var contextExtractorsLock sync.RWMutex
+
+// Registers an extractor whose tags are added to every record logged by the Ctx-variant functions
+func RegisterContextExtractor(extractor ContextExtractor) {
+	contextExtractorsLock.Lock()
+	defer contextExtractorsLock.Unlock()
+	contextExtractors = append(contextExtractors, extractor)
+}
+
+// Returns the extractors registered so far
+func registeredExtractors() []ContextExtractor {
+	contextExtractorsLock.RLock()
+	defer contextExtractorsLock.RUnlock()
+	return contextExtractors
+}
+
+// Adds the OpenTelemetry baggage members carried by the context as tags of the
+// records logged by the Ctx-variant functions. Only the given keys are added,
+// or every member if none is given.
+func LogBaggage(keys ...string) {
+	allowed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		allowed[key] = true
+	}
+	RegisterContextExtractor(func(ctx context.Context) Tags {
+		tags := Tags{}
+		for key, value := range metrics.Baggage(ctx) {
+			if len(allowed) == 0 || allowed[key] {
+				tags[key] = value
+			}
+		}
+		return tags
+	})
+}
+
+// Returns a copy of ctx carrying logCtx, so request-scoped loggers (and their
+// transaction) can be retrieved with FromContext in other layers
+func IntoContext(ctx context.Context, logCtx logContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, logCtx)
+}
+
+// Returns the logContext carried by ctx, or the default one if there is none
+func FromContext(ctx context.Context) logContext {
+	if ctx != nil {
+		if logCtx, ok := ctx.Value(contextKey{}).(logContext); ok {
+			return logCtx
+		}
+	}
+	return defaultContext
+}
+
+// Returns the logContext carried by ctx, tagged with the trace IDs and the
+// remaining deadline found in ctx and with the tags of the registered extractors.
+// Its metrics carry the tags of the request handled with ctx (see metrics.RequestTags).
+func contextFor(ctx context.Context) logContext {
+	logCtx := FromContext(ctx)
+	if ctx == nil {
+		return logCtx
+	}
+	if requestTags := metrics.RequestTagsFrom(ctx); requestTags != nil {
+		logCtx.metricTags = requestTags.Merge(logCtx.metricTags)
+	}
+	tags := Tags{}
+	if traceID, spanID := metrics.TraceIDs(ctx); traceID != "" {
+		tags["trace.id"] = traceID
+		tags["span.id"] = spanID
+	}
+	tags = tags.merge(DeadlineTags(ctx))
+	for _, extractor := range registeredExtractors() {
+		tags = tags.merge(extractor(ctx))
+	}
+	if len(tags) == 0 {
+		return logCtx
+	}
+	return logCtx.WithContext(tags)
+}
+
+// Returns a "deadline_ms" tag with the time left until the deadline of ctx, if it has one
+func DeadlineTags(ctx context.Context) Tags {
+	if deadline, ok := ctx.Deadline(); ok {
+		return Tags{"deadline_ms": format.Milliseconds(clock.Until(deadline))}
+	}
+	return Tags{}
+}
+
+// Logs a warning and pushes a "logging.context_aborted" counter when err (or ctx) shows the
+// operation was aborted because ctx was canceled or its deadline exceeded.
+// Returns whether the operation was aborted.
+func LogAborted(ctx context.Context, operation string, err error) bool {
+	reason := abortReason(ctx, err)
+	if reason == "" {
+		return false
+	}
+	contextFor(ctx).Warn(fmt.Sprintf("Operation \"%s\" aborted: %s", operation, reason),
+		reason, Tags{"operation": operation},
+		metrics.Counter("logging.context_aborted"), metrics.Tags{"operation": operation, "reason": reason})
+	return true
+}
+
+func abortReason(ctx context.Context, err error) string {
+	if err == nil && ctx != nil {
+		err = ctx.Err()
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return ""
+}
+
+func WarnCtx(ctx context.Context, value interface{}, eventsAndTags ...interface{}) {
+	contextFor(ctx).Warn(value, eventsAndTags...)
+}
+
+func ErrorCtx(ctx context.Context, value interface{}, eventsAndTags ...interface{}) error {
+	return contextFor(ctx).Error(value, eventsAndTags...)
+}
+
+func ErrorfCtx(ctx context.Context, format string, a ...interface{}) error {
+	return contextFor(ctx).Errorf(format, a...)
+}
+
+func CriticCtx(ctx context.Context, value interface{}, eventsAndTags ...interface{}) error {
+	return contextFor(ctx).Critic(value, eventsAndTags...)
+}
+
+func InfoCtx(ctx context.Context, value interface{}, eventsAndTags ...interface{}) {
+	contextFor(ctx).Info(value, eventsAndTags...)
+}
+
+func DebugCtx(ctx context.Context, value interface{}, eventsAndTags ...interface{}) {
+	contextFor(ctx).Debug(value, eventsAndTags...)
+}
+
+func TraceCtx(ctx context.Context, value interface{}, eventsAndTags ...interface{}) {
+	contextFor(ctx).Trace(value, eventsAndTags...)
+}
+
+func MetricCtx(ctx context.Context, value interface{}, eventsAndTags ...interface{}) {
+	contextFor(ctx).Metric(value, eventsAndTags...)
+}
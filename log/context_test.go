@@ -0,0 +1,43 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+func TestContextForAddsRequestMetricTags(t *testing.T) {
+	cases := []struct {
+		name     string
+		logCtx   logContext
+		expected metrics.Tags
+	}{
+		{"request tags", defaultContext, metrics.Tags{"endpoint": "/orders", "method": "GET", "status": "200"}},
+		{"context tags win", defaultContext.WithMetricsContext(metrics.Tags{"endpoint": "orders.list"}),
+			metrics.Tags{"endpoint": "orders.list", "method": "GET", "status": "200"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var tags metrics.Tags
+			handler := metrics.TagRequests("/orders", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				tags = contextFor(IntoContext(r.Context(), c.logCtx)).metricTags
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+			if len(tags) != len(c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, tags)
+			}
+			for k, v := range c.expected {
+				if tags[k] != v {
+					t.Errorf("Expected %v, got %v", c.expected, tags)
+				}
+			}
+		})
+	}
+	if tags := contextFor(context.Background()).metricTags; len(tags) != 0 {
+		t.Errorf("Expected no metric tags outside requests, got %v", tags)
+	}
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/pprof"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+)
+
+// Writes a crash report to dir when the process exits on Fatal or on a panic
+// recovered by RecoverFatal. The report holds the fatal record, the last
+// records logged (up to records, kept in an unfiltered RingSink), the build
+// info and a dump of all goroutines.
+func WriteCrashDumps(dir string, records int) {
+	ring := NewRingSink(records)
+	AddSink(Unfiltered(ring))
+	OnFatal(func(record Tags) {
+		path, err := writeCrashDump(dir, record, ring.Records())
+		if err != nil {
+			stderr.Logf("error", "Could not write crash dump: %s", err)
+			return
+		}
+		stderr.Logf("info", "Crash dump written to %s", path)
+	})
+}
+
+func writeCrashDump(dir string, record Tags, records []Tags) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	now := clock.Now().UTC()
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s-%d.txt", now.Format("20060102T150405.000Z"), os.Getpid()))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "=== Crash at %s, pid %d\n\n", now.Format("2006-01-02T15:04:05.000Z07:00"), os.Getpid())
+	fmt.Fprintln(file, "=== Fatal record")
+	writeJSONLine(file, record)
+	fmt.Fprintf(file, "\n=== Last %d records\n", len(records))
+	for _, r := range records {
+		writeJSONLine(file, r)
+	}
+	fmt.Fprintln(file, "\n=== Build info")
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintln(file, info.String())
+	} else {
+		fmt.Fprintln(file, "unavailable")
+	}
+	fmt.Fprintln(file, "\n=== Goroutines")
+	if err := pprof.Lookup("goroutine").WriteTo(file, 2); err != nil {
+		return path, err
+	}
+	return path, file.Sync()
+}
+
+func writeJSONLine(w io.Writer, record Tags) {
+	buf := getBuffer()
+	JSONFormatter{}.Format(buf, record)
+	buf.WriteByte('\n')
+	w.Write(buf.Bytes())
+	putBuffer(buf)
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCrashDump(t *testing.T) {
+	useStoppedClock(t, time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	dir := filepath.Join(t.TempDir(), "crashes")
+	record := Tags{"level": "fatal", "message": "Database unreachable"}
+	records := []Tags{{"message": "Started"}, {"message": "Connecting"}}
+
+	path, err := writeCrashDump(dir, record, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(dir, "crash-20240501T123000.000Z-"); !strings.HasPrefix(path, expected) {
+		t.Errorf("Expected the dump written to %s*, got %s", expected, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := string(data)
+	for _, expected := range []string{
+		"=== Crash at 2024-05-01T12:30:00.000Z, pid ",
+		"=== Fatal record\n{",
+		"\"message\":\"Database unreachable\"",
+		"=== Last 2 records\n{\"message\":\"Started\"}\n{\"message\":\"Connecting\"}\n",
+		"=== Build info\n",
+		"=== Goroutines\n",
+		"TestWriteCrashDump",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Expected %q in the dump:\n%s", expected, dump)
+		}
+	}
+
+	if _, err := writeCrashDump(dir, record, nil); err == nil {
+		t.Errorf("Expected an error instead of overwriting the dump written at the same time")
+	}
+}
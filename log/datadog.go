@@ -0,0 +1,96 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+// Entries the Datadog intake accepts per request
+const datadogMaxBatchSize = 1000
+
+// Configuration of DatadogSink
+type DatadogSinkConfig struct {
+	APIKey string
+	// Datadog site of the organization, "datadoghq.com" by default ("datadoghq.eu", "us3.datadoghq.com", ...)
+	Site    string
+	Service string
+	// Integration processing the logs, "go" by default
+	Source string
+	// Sent as ddtags, "env:prod,version:1.2"
+	Tags map[string]string
+	// os.Hostname() by default
+	Hostname string
+	// Batching, queueing and retries. URL, Encode and Headers are set by the sink.
+	HTTP HTTPSinkConfig
+}
+
+// Sink sending records to the Datadog Logs HTTP intake, for hosts without an
+// agent or forwarder. Records keep their tags as attributes; the level is sent
+// as the status of each log.
+type DatadogSink struct {
+	*HTTPSink
+}
+
+func NewDatadogSink(config DatadogSinkConfig) (*DatadogSink, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("Missing Datadog API key")
+	}
+	if config.Site == "" {
+		config.Site = "datadoghq.com"
+	}
+	if config.Source == "" {
+		config.Source = "go"
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	attributes := Tags{"ddsource": config.Source, "hostname": config.Hostname}
+	if config.Service != "" {
+		attributes["service"] = config.Service
+	}
+	if ddtags := datadogTags(config.Tags); ddtags != "" {
+		attributes["ddtags"] = ddtags
+	}
+	httpConfig := config.HTTP
+	httpConfig.URL = "https://http-intake.logs." + config.Site + "/api/v2/logs"
+	httpConfig.Headers = map[string]string{"DD-API-KEY": config.APIKey}
+	httpConfig.ContentType = "application/json"
+	if httpConfig.BatchSize <= 0 || httpConfig.BatchSize > datadogMaxBatchSize {
+		httpConfig.BatchSize = datadogMaxBatchSize
+	}
+	httpConfig.Encode = func(records []Tags) ([]byte, error) {
+		entries := make([]Tags, len(records))
+		for i, record := range records {
+			entries[i] = datadogEntry(record, attributes)
+		}
+		return jsonArrayEncoder(entries)
+	}
+	return &DatadogSink{NewHTTPSink(httpConfig)}, nil
+}
+
+func (sink *DatadogSink) Write(record Tags) error {
+	return sink.HTTPSink.Write(stamped(record, "timestamp", clock.Now().UnixNano()/1e6))
+}
+
+// Returns the log entry sent for record
+func datadogEntry(record Tags, attributes Tags) Tags {
+	entry := record.merge(attributes)
+	if level, ok := record["level"]; ok {
+		entry["status"] = level
+	}
+	return entry
+}
+
+// Returns tags as sorted "key:value" pairs separated by commas
+func datadogTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+":"+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
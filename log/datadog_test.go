@@ -0,0 +1,54 @@
+package log
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDatadogTags(t *testing.T) {
+	cases := []struct {
+		tags     map[string]string
+		expected string
+	}{
+		{nil, ""},
+		{map[string]string{"env": "prod"}, "env:prod"},
+		{map[string]string{"version": "1.2", "env": "prod"}, "env:prod,version:1.2"},
+	}
+	for _, c := range cases {
+		if ddtags := datadogTags(c.tags); ddtags != c.expected {
+			t.Errorf("%v: expected %s, got %s", c.tags, c.expected, ddtags)
+		}
+	}
+}
+
+func TestDatadogEntry(t *testing.T) {
+	attributes := Tags{"ddsource": "go", "service": "api"}
+	cases := []struct {
+		record   Tags
+		expected Tags
+	}{
+		{Tags{"level": "warn", "message": "slow"}, Tags{"level": "warn", "status": "warn", "message": "slow", "ddsource": "go", "service": "api"}},
+		{Tags{"message": "no level"}, Tags{"message": "no level", "ddsource": "go", "service": "api"}},
+		// The sink attributes win over the record tags
+		{Tags{"service": "other"}, Tags{"ddsource": "go", "service": "api"}},
+	}
+	for _, c := range cases {
+		if entry := datadogEntry(c.record, attributes); !reflect.DeepEqual(entry, c.expected) {
+			t.Errorf("%v: expected %v, got %v", c.record, c.expected, entry)
+		}
+	}
+}
+
+func TestNewDatadogSink(t *testing.T) {
+	if _, err := NewDatadogSink(DatadogSinkConfig{}); err == nil {
+		t.Error("Expected an error without API key")
+	}
+	sink, err := NewDatadogSink(DatadogSinkConfig{APIKey: "key", Site: "datadoghq.eu", HTTP: HTTPSinkConfig{BatchSize: 5000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if sink.config.URL != "https://http-intake.logs.datadoghq.eu/api/v2/logs" || sink.config.BatchSize != datadogMaxBatchSize {
+		t.Errorf("Unexpected config %+v", sink.config)
+	}
+}
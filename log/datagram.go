@@ -0,0 +1,191 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Largest UDP payload sent without IP fragmentation on an Ethernet network
+const defaultMaxDatagramSize = 1472
+
+// Time Write waits for a collector whose receive buffer is full
+const datagramWriteTimeout = 100 * time.Millisecond
+
+// Appended to messages shortened to fit in a datagram
+const truncatedMarker = "...(truncated)"
+
+// Header starting each chunk of a split record: the ID shared by the chunks of
+// the record, the chunk index from 1 and the number of chunks
+const chunkHeaderFormat = "%s %04d/%04d\n"
+const chunkHeaderSize = 16 + 1 + 4 + 1 + 4 + 1
+const maxChunks = 9999
+
+// Configuration of UDPSink
+type UDPSinkConfig struct {
+	Address string
+	// Records encoded larger than this (1472 bytes by default) are truncated or split
+	MaxDatagramSize int
+	// Sends oversized records as several datagrams instead of truncating them.
+	// Each chunk starts with a "<record id> <index>/<count>" header line, e.g.
+	// "00c0ffee00c0ffee 0002/0003", so the collector can reassemble the record.
+	Split bool
+	// JSONFormatter by default
+	Formatter Formatter
+}
+
+// Sink sending each record as a datagram to a local collector. Records are lost
+// when the collector is down or the network drops them, and Write waits at most
+// 100ms for a collector whose receive buffer is full.
+type UDPSink struct {
+	*datagramSink
+}
+
+func NewUDPSink(config UDPSinkConfig) (*UDPSink, error) {
+	addr, err := net.ResolveUDPAddr("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve %s: %s", config.Address, err)
+	}
+	dial := func() (net.Conn, error) {
+		return net.DialUDP("udp", nil, addr)
+	}
+	return &UDPSink{newDatagramSink(dial, config.Formatter, config.MaxDatagramSize, config.Split)}, nil
+}
+
+// Writes one record per datagram, truncating or splitting those larger than
+// maxSize, and dropping those that cannot be. Connects on the first write and
+// again after a failed one.
+type datagramSink struct {
+	dial      func() (net.Conn, error)
+	conn      net.Conn
+	formatter Formatter
+	maxSize   int
+	split     bool
+	lock      sync.Mutex
+	truncated int
+	dropped   int
+	lastError error
+}
+
+func newDatagramSink(dial func() (net.Conn, error), formatter Formatter, maxSize int, split bool) *datagramSink {
+	if formatter == nil {
+		formatter = JSONFormatter{}
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxDatagramSize
+	}
+	return &datagramSink{dial: dial, formatter: formatter, maxSize: maxSize, split: split}
+}
+
+func (sink *datagramSink) Write(record Tags) error {
+	datagrams, err := sink.datagrams(record)
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if err != nil {
+		sink.dropped++
+		sink.lastError = err
+		return err
+	}
+	if sink.conn == nil {
+		conn, err := sink.dial()
+		if err != nil {
+			sink.dropped++
+			sink.lastError = err
+			return err
+		}
+		sink.conn = conn
+	}
+	sink.conn.SetWriteDeadline(time.Now().Add(datagramWriteTimeout))
+	for _, datagram := range datagrams {
+		if _, err := sink.conn.Write(datagram); err != nil {
+			sink.dropped++
+			sink.lastError = err
+			if e, ok := err.(net.Error); !ok || !e.Timeout() {
+				sink.conn.Close()
+				sink.conn = nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (sink *datagramSink) encode(record Tags) []byte {
+	buf := new(bytes.Buffer)
+	sink.formatter.Format(buf, record)
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// Returns the datagrams record is sent as. When truncating, the message is
+// shortened so the datagram still holds a well formed record; records whose tags
+// alone exceed the size are not sent.
+func (sink *datagramSink) datagrams(record Tags) ([][]byte, error) {
+	encoded := sink.encode(record)
+	if len(encoded) <= sink.maxSize {
+		return [][]byte{encoded}, nil
+	}
+	if sink.split {
+		return sink.chunks(encoded)
+	}
+	if message, ok := record["message"].(string); ok {
+		if keep := len(message) - (len(encoded) - sink.maxSize) - len(truncatedMarker); keep > 0 {
+			shortened := Tags{}.merge(record)
+			shortened["message"] = message[:keep] + truncatedMarker
+			if encoded := sink.encode(shortened); len(encoded) <= sink.maxSize {
+				sink.lock.Lock()
+				sink.truncated++
+				sink.lock.Unlock()
+				return [][]byte{encoded}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Record of %d bytes dropped: its tags exceed %d bytes", len(encoded), sink.maxSize)
+}
+
+// Splits encoded into chunks of at most maxSize bytes, each starting with its header
+func (sink *datagramSink) chunks(encoded []byte) ([][]byte, error) {
+	size := sink.maxSize - chunkHeaderSize
+	if size <= 0 {
+		return nil, fmt.Errorf("Record of %d bytes dropped: %d bytes do not fit a chunk", len(encoded), sink.maxSize)
+	}
+	count := (len(encoded) + size - 1) / size
+	if count > maxChunks {
+		return nil, fmt.Errorf("Record of %d bytes dropped: more than %d chunks", len(encoded), maxChunks)
+	}
+	id := randomID()
+	datagrams := make([][]byte, 0, count)
+	for i := 1; len(encoded) > 0; i++ {
+		n := size
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		datagram := make([]byte, 0, chunkHeaderSize+n)
+		datagram = append(datagram, fmt.Sprintf(chunkHeaderFormat, id, i, count)...)
+		datagrams = append(datagrams, append(datagram, encoded[:n]...))
+		encoded = encoded[n:]
+	}
+	return datagrams, nil
+}
+
+func (sink *datagramSink) Close() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if sink.conn == nil {
+		return nil
+	}
+	err := sink.conn.Close()
+	sink.conn = nil
+	return err
+}
+
+func (sink *datagramSink) Status() Tags {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	status := Tags{"connected": sink.conn != nil, "max_datagram_size": sink.maxSize, "truncated": sink.truncated, "dropped": sink.dropped}
+	if sink.lastError != nil {
+		status["last_error"] = sink.lastError.Error()
+	}
+	return status
+}
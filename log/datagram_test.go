@@ -0,0 +1,95 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatagrams(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	cases := []struct {
+		name     string
+		split    bool
+		record   Tags
+		expected []string
+		dropped  bool
+	}{
+		{"fits", false, Tags{"message": "short"}, []string{`{"message":"short"}`}, false},
+		{"truncates message", false, Tags{"message": long},
+			[]string{`{"message":"` + long[:100-len(`{"message":""}`)-len(truncatedMarker)] + truncatedMarker + `"}`}, false},
+		{"drops records without message", false, Tags{"payload": long}, nil, true},
+		{"splits", true, Tags{"message": long}, []string{
+			(`{"message":"` + long)[:73],
+			(`{"message":"` + long + `"}`)[73:146],
+			(`{"message":"` + long + `"}`)[146:]}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sink := newDatagramSink(nil, nil, 100, c.split)
+			datagrams, err := sink.datagrams(c.record)
+			if (err != nil) != c.dropped {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if len(datagrams) != len(c.expected) {
+				t.Fatalf("Expected %d datagrams, got %d: %q", len(c.expected), len(datagrams), datagrams)
+			}
+			var id string
+			for i, datagram := range datagrams {
+				if len(datagram) > 100 {
+					t.Errorf("Datagram %d is %d bytes", i, len(datagram))
+				}
+				if c.split {
+					header := fmt.Sprintf(" %04d/%04d\n", i+1, len(datagrams))
+					if i == 0 {
+						id = string(datagram[:16])
+					}
+					if !strings.HasPrefix(string(datagram), id+header) {
+						t.Errorf("Datagram %d: unexpected header in %q", i, datagram)
+					}
+					datagram = datagram[chunkHeaderSize:]
+				}
+				if string(datagram) != c.expected[i] {
+					t.Errorf("Datagram %d: expected %s, got %s", i, c.expected[i], datagram)
+				}
+			}
+		})
+	}
+}
+
+func TestDatagramSinkCountsDroppedRecords(t *testing.T) {
+	sink := newDatagramSink(nil, nil, 100, false)
+	if err := sink.Write(Tags{"payload": strings.Repeat("x", 200)}); err == nil {
+		t.Error("Expected an error for a record that cannot be truncated")
+	}
+	if status := sink.Status(); status["dropped"] != 1 || status["truncated"] != 0 {
+		t.Errorf("Unexpected status %v", status)
+	}
+}
+
+func TestUDPSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sink, err := NewUDPSink(UDPSinkConfig{Address: conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Write(Tags{"message": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, defaultMaxDatagramSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received := string(buf[:n]); received != `{"message":"hello"}` {
+		t.Errorf("Unexpected datagram %s", received)
+	}
+}
@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// Headers whose values DumpHTTP replaces with "[REDACTED]"
+var RedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Size after which DumpHTTP truncates each dump
+var DumpHTTPMaxSize = 16 * 1024
+
+// Logs at TRACE a dump of an HTTP request and its response (either may be nil),
+// with sensitive headers redacted and each dump capped at DumpHTTPMaxSize.
+// Only the first DumpHTTPMaxSize bytes of the bodies are read, and put back, so
+// req and resp can still be used afterwards. Does nothing, not even reading the
+// bodies, unless TRACE is enabled.
+func DumpHTTP(ctx context.Context, req *http.Request, resp *http.Response) {
+	if !FromContext(ctx).enabled(TRACE) {
+		return
+	}
+	tags := Tags{}
+	if req != nil {
+		dumped := *req
+		dumped.Header = redactHeaders(req.Header)
+		dumped.Body, req.Body = peekBody(req.Body)
+		dump, err := httputil.DumpRequest(&dumped, req.Body != nil)
+		tags["http.request"] = capDump(dump, err)
+	}
+	if resp != nil {
+		dumped := *resp
+		dumped.Header = redactHeaders(resp.Header)
+		dumped.Body, resp.Body = peekBody(resp.Body)
+		dump, err := httputil.DumpResponse(&dumped, resp.Body != nil)
+		tags["http.response"] = capDump(dump, err)
+	}
+	contextFor(ctx).Trace("HTTP dump", "http.dump", tags)
+}
+
+// Reads up to DumpHTTPMaxSize + 1 bytes of body. Returns a body holding them, to
+// be dumped, and one reading them followed by the rest, to replace body.
+func peekBody(body io.ReadCloser) (io.ReadCloser, io.ReadCloser) {
+	if body == nil || body == http.NoBody {
+		return body, body
+	}
+	head, err := io.ReadAll(io.LimitReader(body, int64(DumpHTTPMaxSize)+1))
+	var rest io.Reader = body
+	if err != nil {
+		rest = errorReader{err}
+	}
+	return io.NopCloser(bytes.NewReader(head)), readCloser{io.MultiReader(bytes.NewReader(head), rest), body}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Returns the error that interrupted the peek of a body once its head was read
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range RedactedHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
+func capDump(dump []byte, err error) string {
+	if err != nil {
+		return fmt.Sprintf("[dump failed: %s]", err)
+	}
+	str := strings.TrimRight(string(dump), "\r\n")
+	if len(str) > DumpHTTPMaxSize {
+		return str[:DumpHTTPMaxSize] + truncatedMarker
+	}
+	return str
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Counts the bytes read from it
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	return nil
+}
+
+func TestDumpHTTPReadsBodiesUpToTheLimit(t *testing.T) {
+	defer func(size int) { DumpHTTPMaxSize = size }(DumpHTTPMaxSize)
+	DumpHTTPMaxSize = 100
+	logger, sink := recordingContext()
+	ctx := IntoContext(context.Background(), logger)
+	cases := []struct {
+		name string
+		body string
+	}{
+		{"small", "name=orders"},
+		{"large", strings.Repeat("x", 10*DumpHTTPMaxSize)},
+	}
+	for _, c := range cases {
+		body := &countingReader{Reader: strings.NewReader(c.body)}
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com/orders", body)
+		req.Header.Set("Authorization", "Bearer secret")
+		sink.records = nil
+		DumpHTTP(ctx, req, nil)
+		if body.read > DumpHTTPMaxSize+1 {
+			t.Errorf("%s: read %d bytes of the body", c.name, body.read)
+		}
+		if rest, _ := io.ReadAll(req.Body); string(rest) != c.body {
+			t.Errorf("%s: the body was not restored, got %d bytes", c.name, len(rest))
+		}
+		if len(sink.records) != 1 {
+			t.Fatalf("%s: expected a dump, got %v", c.name, sink.records)
+		}
+		dump := sink.records[0]["http.request"].(string)
+		if strings.Contains(dump, "secret") || len(dump) > DumpHTTPMaxSize+len(truncatedMarker) {
+			t.Errorf("%s: unexpected dump %q", c.name, dump)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// Encoding of the tags of a context, computed once on its first emitted record
+// and reused as the prefix of every following one
+type encodedContext struct {
+	once       sync.Once
+	generation uint64 // Of the formatter that encoded prefix
+	keys       map[string]bool
+	prefix     []byte
+}
+
+func (cache *encodedContext) init(f fields, tf tagFormatter, generation uint64) {
+	cache.once.Do(func() {
+		tags := f.tags()
+		cache.generation = generation
+		cache.keys = make(map[string]bool, len(tags))
+		buf := new(bytes.Buffer)
+		tf.Start(buf)
+		for k, v := range tags {
+			tf.AppendTag(buf, len(cache.keys), k, v)
+			cache.keys[k] = true
+		}
+		cache.prefix = buf.Bytes()
+	})
+}
+
+// Writes record to the output reusing the encoded context tags, unless the
+// formatter can't encode tag by tag, it changed since the tags were encoded, or
+// the record overrides any of them. Returns whether it was written.
+func (cache *encodedContext) write(f fields, added fields, record Tags) bool {
+	generation := atomic.LoadUint64(&formatterGeneration)
+	tf, ok := formatter.(tagFormatter)
+	if !ok {
+		return false
+	}
+	cache.init(f, tf, generation)
+	if cache.generation != generation {
+		return false
+	}
+	for _, field := range added {
+		if cache.keys[field.key] {
+			return false
+		}
+	}
+	buf := getBuffer()
+	buf.Write(cache.prefix)
+	n := len(cache.keys)
+	for k, v := range record {
+		if !cache.keys[k] {
+			tf.AppendTag(buf, n, k, v)
+			n++
+		}
+	}
+	tf.End(buf)
+	buf.WriteByte('\n')
+	output.Write(buf.Bytes())
+	putBuffer(buf)
+	return true
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Not comparable, so comparing it as an interface value panics
+type mapFormatter struct {
+	JSONFormatter
+	names map[string]string
+}
+
+func TestEncodedContextFormatterChange(t *testing.T) {
+	var out bytes.Buffer
+	withLevel(t, INFO)
+	SetOutput(&out)
+	defer SetFormatter(BracketsFormatter{})
+	context := WithContext(Tags{"app": "test"})
+
+	SetFormatter(BracketsFormatter{})
+	context.Info("first")
+	SetFormatter(mapFormatter{names: map[string]string{}})
+	context.Info("second")
+	other := WithContext(Tags{"app": "other"})
+	other.Info("third")
+	other.Info("fourth")
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 4 || lines[0][0] != '[' || lines[1][0] != '{' || lines[3][0] != '{' {
+		t.Errorf("Unexpected output\n%s", out.String())
+	}
+}
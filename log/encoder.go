@@ -0,0 +1,130 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+// Version of the Elastic Common Schema followed by ECSFormatter
+const ECS_VERSION = "8.11.0"
+
+// Changes a copy of each record before it is encoded by a sink
+type FieldOption func(record Tags)
+
+// Renames the fields found in names to the name they map to
+func RenameFields(names map[string]string) FieldOption {
+	return func(record Tags) {
+		for from, to := range names {
+			if value, ok := record[from]; ok {
+				delete(record, from)
+				record[to] = value
+			}
+		}
+	}
+}
+
+// Removes the given fields
+func OmitFields(keys ...string) FieldOption {
+	return func(record Tags) {
+		for _, key := range keys {
+			delete(record, key)
+		}
+	}
+}
+
+// Returns a copy of record changed by options, or record itself if there are none
+func applyFieldOptions(record Tags, options []FieldOption) Tags {
+	if len(options) == 0 {
+		return record
+	}
+	record = record.merge(nil)
+	for _, option := range options {
+		option(record)
+	}
+	return record
+}
+
+// Sink writing each record as a line to w, encoded with its own formatter and
+// field options instead of the global ones. With SetOutput(io.Discard), each
+// destination gets its own format:
+//
+//	log.AddSink(log.NewWriterSink(os.Stderr, log.PrettyFormatter{}))
+//	log.AddSink(log.NewWriterSink(file, log.ECSFormatter{}, log.OmitFields("fingerprint")))
+type WriterSink struct {
+	lock      sync.Mutex
+	w         io.Writer
+	formatter Formatter
+	options   []FieldOption
+}
+
+func NewWriterSink(w io.Writer, formatter Formatter, options ...FieldOption) *WriterSink {
+	return &WriterSink{w: w, formatter: formatter, options: options}
+}
+
+func (sink *WriterSink) Write(record Tags) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	sink.formatter.Format(buf, applyFieldOptions(record, sink.options))
+	buf.WriteByte('\n')
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	_, err := sink.w.Write(buf.Bytes())
+	return err
+}
+
+// Flushes w if it buffers, as BufferedWriter does
+func (sink *WriterSink) Flush() error {
+	if flusher, ok := sink.w.(sinkFlusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+type mappedSink struct {
+	sink    Sink
+	options []FieldOption
+}
+
+// Wraps sink so it receives the records changed by options, e.g. to rename the
+// fields a remote collector expects under other names
+func MapFields(sink Sink, options ...FieldOption) Sink {
+	return &mappedSink{sink, options}
+}
+
+func (m *mappedSink) Write(record Tags) error {
+	return m.sink.Write(applyFieldOptions(record, m.options))
+}
+
+func (m *mappedSink) Flush() error {
+	if flusher, ok := m.sink.(sinkFlusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// JSON following the Elastic Common Schema: the time of formatting as
+// "@timestamp" (in the timezone set with clock.SetLocation), the level as "log.level", the event as "event.action" and the
+// logger name as "log.logger". Other tags are kept as they are.
+type ECSFormatter struct{}
+
+func (ECSFormatter) Format(buf *bytes.Buffer, record Tags) {
+	ecs := make(Tags, len(record)+2)
+	ecs["@timestamp"] = clock.Timestamp().Format("2006-01-02T15:04:05.000Z07:00")
+	ecs["ecs.version"] = ECS_VERSION
+	for k, v := range record {
+		switch k {
+		case "level":
+			ecs["log.level"] = v
+		case "event":
+			ecs["event.action"] = v
+		case "logger":
+			ecs["log.logger"] = v
+		default:
+			ecs[k] = v
+		}
+	}
+	JSONFormatter{}.Format(buf, ecs)
+}
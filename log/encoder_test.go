@@ -0,0 +1,78 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFieldOptions(t *testing.T) {
+	cases := []struct {
+		name     string
+		options  []FieldOption
+		expected Tags
+	}{
+		{"none", nil, Tags{"level": "info", "message": "hello", "fingerprint": "abc"}},
+		{"rename", []FieldOption{RenameFields(map[string]string{"message": "msg", "missing": "other"})},
+			Tags{"level": "info", "msg": "hello", "fingerprint": "abc"}},
+		{"omit", []FieldOption{OmitFields("fingerprint", "missing")}, Tags{"level": "info", "message": "hello"}},
+		{"in order", []FieldOption{RenameFields(map[string]string{"level": "severity"}), OmitFields("severity")},
+			Tags{"message": "hello", "fingerprint": "abc"}},
+	}
+	for _, c := range cases {
+		record := Tags{"level": "info", "message": "hello", "fingerprint": "abc"}
+		mapped := applyFieldOptions(record, c.options)
+		if !reflect.DeepEqual(mapped, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, mapped)
+		}
+		if len(record) != 3 || record["message"] != "hello" {
+			t.Errorf("%s: the original record was changed: %v", c.name, record)
+		}
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	cases := []struct {
+		formatter Formatter
+		options   []FieldOption
+		expected  string
+	}{
+		{JSONFormatter{}, nil, "{\"message\":\"hello\"}\n"},
+		{LogfmtFormatter{}, []FieldOption{RenameFields(map[string]string{"message": "msg"})}, "msg=hello\n"},
+	}
+	for _, c := range cases {
+		buf := new(bytes.Buffer)
+		sink := NewWriterSink(buf, c.formatter, c.options...)
+		sink.Write(Tags{"message": "hello"})
+		if buf.String() != c.expected {
+			t.Errorf("%T: expected %q, got %q", c.formatter, c.expected, buf.String())
+		}
+	}
+}
+
+func TestMapFields(t *testing.T) {
+	ring := NewRingSink(1)
+	MapFields(ring, OmitFields("secret")).Write(Tags{"message": "hello", "secret": "s3cr3t"})
+	if records := ring.Records(); len(records) != 1 || records[0]["secret"] != nil {
+		t.Errorf("Unexpected records %v", records)
+	}
+}
+
+func TestECSFormatter(t *testing.T) {
+	useStoppedClock(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	buf := new(bytes.Buffer)
+	ECSFormatter{}.Format(buf, Tags{"level": "warn", "message": "slow", "event": "db.slow", "logger": "db", "table": "users"})
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"@timestamp": "2024-05-01T12:00:00.000Z", "ecs.version": ECS_VERSION, "log.level": "warn", "message": "slow",
+		"event.action": "db.slow", "log.logger": "db", "table": "users",
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %v, got %v", expected, decoded)
+	}
+}
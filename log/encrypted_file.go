@@ -0,0 +1,123 @@
+package log
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Returns the 32 bytes AES-256 key used to encrypt log files, e.g. fetched from a KMS
+type KeyProvider func() ([]byte, error)
+
+// Reads a base64 encoded key from the given environment variable
+func KeyFromEnv(name string) KeyProvider {
+	return func() ([]byte, error) {
+		value := os.Getenv(name)
+		if value == "" {
+			return nil, fmt.Errorf("Environment variable %s is not set", name)
+		}
+		return base64.StdEncoding.DecodeString(value)
+	}
+}
+
+// Sink appending records to a file encrypted with AES-GCM. Each record is written
+// as a self-contained chunk (4 bytes length, nonce, ciphertext), so files that were
+// partially written remain decryptable up to the last complete chunk.
+type EncryptedFileSink struct {
+	lock sync.Mutex
+	file *os.File
+	aead cipher.AEAD
+}
+
+func NewEncryptedFileSink(path string, key KeyProvider) (*EncryptedFileSink, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open log file: %s", err)
+	}
+	return &EncryptedFileSink{file: file, aead: aead}, nil
+}
+
+func (sink *EncryptedFileSink) Write(record Tags) error {
+	nonce := make([]byte, sink.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := sink.aead.Seal(nonce, nonce, []byte(formatLine(record)+"\n"), nil)
+	chunk := make([]byte, 4, 4+len(sealed))
+	binary.BigEndian.PutUint32(chunk, uint32(len(sealed)))
+	chunk = append(chunk, sealed...)
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	_, err := sink.file.Write(chunk)
+	return err
+}
+
+func (sink *EncryptedFileSink) Flush() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	return sink.file.Sync()
+}
+
+func (sink *EncryptedFileSink) Close() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	return sink.file.Close()
+}
+
+// Decrypts a file written by EncryptedFileSink into w. A truncated last chunk is ignored.
+func DecryptLogFile(r io.Reader, key KeyProvider, w io.Writer) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		sealed := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(r, sealed); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return errors.New("Invalid encrypted log chunk")
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plain, err := aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return fmt.Errorf("Could not decrypt log chunk: %s", err)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+	}
+}
+
+func newAEAD(key KeyProvider) (cipher.AEAD, error) {
+	k, err := key()
+	if err != nil {
+		return nil, fmt.Errorf("Could not get log encryption key: %s", err)
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid log encryption key: %s", err)
+	}
+	return cipher.NewGCM(block)
+}
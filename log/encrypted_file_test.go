@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func staticKey(b byte) KeyProvider {
+	return func() ([]byte, error) {
+		return bytes.Repeat([]byte{b}, 32), nil
+	}
+}
+
+func writeEncrypted(t *testing.T, messages ...string) []byte {
+	path := filepath.Join(t.TempDir(), "log.enc")
+	sink, err := NewEncryptedFileSink(path, staticKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range messages {
+		if err := sink.Write(Tags{"message": message}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecryptLogFile(t *testing.T) {
+	data := writeEncrypted(t, "first", "second")
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	cases := []struct {
+		name     string
+		data     []byte
+		key      KeyProvider
+		expected string
+		fails    bool
+	}{
+		{"round trip", data, staticKey(1), "[message:first]\n[message:second]\n", false},
+		{"truncated last chunk", data[:len(data)-5], staticKey(1), "[message:first]\n", false},
+		{"truncated header", data[:len(data)/2+2], staticKey(1), "[message:first]\n", false},
+		{"wrong key", data, staticKey(2), "", true},
+		{"tampered ciphertext", tampered, staticKey(1), "[message:first]\n", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := DecryptLogFile(bytes.NewReader(c.data), c.key, &out)
+			if (err != nil) != c.fails {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if out.String() != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+)
+
+var samplingRate = 1.0
+var globalFields fields
+
+// Keeps only the given fraction (0 to 1) of the trace, debug, info and metric
+// records. Warnings and errors are never sampled out.
+func SetSampling(rate float64) {
+	samplingRate = rate
+}
+
+func sampled(level string) bool {
+	if samplingRate >= 1 {
+		return true
+	}
+	switch level {
+	case "trace", "debug", "info", "metric":
+		return rand.Float64() < samplingRate
+	}
+	return true
+}
+
+// Tags added to every record, overridden by the tags of contexts and calls
+func SetGlobalTags(tags Tags) {
+	globalFields = fields(nil).with(tags)
+}
+
+// Configures the package from the environment:
+//
+//	LOG_LEVEL        level name or number
+//	LOG_FORMAT       brackets, ecs, json, logfmt, pretty or stdlib
+//	LOG_OUTPUT       stdout, stderr or a file path (appended)
+//	LOG_SAMPLING     fraction of trace, debug, info and metric records kept
+//	LOG_SEQUENCE     true to stamp records with "seq" and "record_id"
+//	LOG_TRACE_IDS    true to stamp the records of transactions with "trace.id" and "span.id"
+//	LOG_TIMEZONE     timezone of the timestamps: UTC (the default), Local or an IANA name
+//	LOG_CLOCK_OFFSET duration added to the host clock when it is known to be skewed, e.g. -1.5s
+//	LOG_TAGS         key:value pairs added to every record, e.g. team:payments,region:us-east-1
+//	LOG_GLOBAL_TAGS  JSON object of tags added to every record, overriding LOG_TAGS
+//	LOG_POLICY_FILE  JSON file of the policies applied to the records, see Policy
+//
+// Invalid values are reported on stderr and ignored, except LOG_LEVEL which panics.
+func ConfigureFromEnv() {
+	SetLevelFromEnv()
+	if name := os.Getenv("LOG_FORMAT"); name != "" {
+		if f, err := FormatterByName(name); err != nil {
+			envError(err)
+		} else {
+			SetFormatter(f)
+		}
+	}
+	if path := os.Getenv("LOG_OUTPUT"); path != "" {
+		switch path {
+		case "stdout":
+			SetOutput(os.Stdout)
+		case "stderr":
+			SetOutput(os.Stderr)
+		default:
+			if file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+				envError(fmt.Errorf("Could not open LOG_OUTPUT: %s", err))
+			} else {
+				SetOutput(file)
+			}
+		}
+	}
+	if value := os.Getenv("LOG_SAMPLING"); value != "" {
+		if rate, err := strconv.ParseFloat(value, 64); err != nil || rate < 0 || rate > 1 {
+			envError(fmt.Errorf("Invalid LOG_SAMPLING: %s", value))
+		} else {
+			SetSampling(rate)
+		}
+	}
+	if value := os.Getenv("LOG_SEQUENCE"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err != nil {
+			envError(fmt.Errorf("Invalid LOG_SEQUENCE: %s", value))
+		} else {
+			SequenceRecords(enabled)
+		}
+	}
+	if value := os.Getenv("LOG_TRACE_IDS"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err != nil {
+			envError(fmt.Errorf("Invalid LOG_TRACE_IDS: %s", value))
+		} else {
+			TraceRecords(enabled)
+		}
+	}
+	if value := os.Getenv("LOG_TIMEZONE"); value != "" {
+		if loc, err := time.LoadLocation(value); err != nil {
+			envError(fmt.Errorf("Invalid LOG_TIMEZONE: %s", err))
+		} else {
+			clock.SetLocation(loc)
+		}
+	}
+	if value := os.Getenv("LOG_CLOCK_OFFSET"); value != "" {
+		if d, err := time.ParseDuration(value); err != nil {
+			envError(fmt.Errorf("Invalid LOG_CLOCK_OFFSET: %s", value))
+		} else {
+			clock.SetOffset(d)
+		}
+	}
+	global := Tags{}
+	if value := os.Getenv("LOG_TAGS"); value != "" {
+		if tags, err := ParseTags(value); err != nil {
+			envError(fmt.Errorf("Invalid LOG_TAGS: %s", err))
+		} else {
+			global = global.merge(tags)
+		}
+	}
+	if value := os.Getenv("LOG_GLOBAL_TAGS"); value != "" {
+		tags := Tags{}
+		if err := json.Unmarshal([]byte(value), &tags); err != nil {
+			envError(fmt.Errorf("Invalid LOG_GLOBAL_TAGS: %s", err))
+		} else {
+			global = global.merge(tags)
+		}
+	}
+	if len(global) > 0 {
+		SetGlobalTags(global)
+	}
+	if path := os.Getenv("LOG_POLICY_FILE"); path != "" {
+		if err := LoadPolicies(path); err != nil {
+			envError(err)
+		}
+	}
+}
+
+// Parses a comma separated list of key:value pairs, e.g. "team:payments,region:us-east-1"
+func ParseTags(value string) (Tags, error) {
+	tags := Tags{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("expected key:value, got %q", pair)
+		}
+		tags[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return tags, nil
+}
+
+func envError(err error) {
+	stderr.Logf("error", "%s", err)
+}
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+func TestUnloggedRecordsPushTheirMetrics(t *testing.T) {
+	defer SetSampling(1)
+	cases := []struct {
+		name     string
+		sampling float64
+		log      func(context logContext)
+	}{
+		{"sampled out", 0, func(c logContext) {
+			c.Info("Order created", metrics.Counter("orders.created"), metrics.Tags{"shop": "eu"})
+		}},
+		{"muted", 1, func(c logContext) {
+			c.If(false).Log("info", "Order created", metrics.Counter("orders.created"), metrics.Tags{"shop": "eu"})
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			backend := usePushedMetrics(t)
+			SetSampling(c.sampling)
+			context, sink := recordingContext()
+			c.log(context)
+			if len(sink.records) != 0 {
+				t.Errorf("Expected no record, got %v", sink.records)
+			}
+			if recorded := backend.recorded(); len(recorded) != 1 || recorded[0] != "orders.created 1 shop:eu" {
+				t.Errorf("Expected the counter to be pushed, got %v", recorded)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Error classes
+const (
+	CLASS_RETRYABLE  = "retryable"
+	CLASS_CLIENT     = "client"
+	CLASS_DEPENDENCY = "dependency"
+	CLASS_INTERNAL   = "internal"
+)
+
+// Implemented by errors that know their class
+type ClassifiedError interface {
+	error
+	ErrorClass() string
+}
+
+// Returns the class of err, or false if it does not recognize it
+type ErrorClassifier func(err error) (class string, ok bool)
+
+func init() {
+	metrics.SetErrorClassifier(ClassifyError)
+}
+
+var errorClassifiers []ErrorClassifier
+var errorClassifiersLock sync.RWMutex
+
+// Registers a classifier consulted, in registration order, for errors that do not
+// implement ClassifiedError
+func RegisterErrorClassifier(classifier ErrorClassifier) {
+	errorClassifiersLock.Lock()
+	defer errorClassifiersLock.Unlock()
+	errorClassifiers = append(errorClassifiers, classifier)
+}
+
+// Returns the class of err (or of the errors it wraps), or "" if it is unknown
+func ClassifyError(err error) string {
+	var classified ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.ErrorClass()
+	}
+	errorClassifiersLock.RLock()
+	defer errorClassifiersLock.RUnlock()
+	for _, classifier := range errorClassifiers {
+		if class, ok := classifier(err); ok {
+			return class
+		}
+	}
+	return ""
+}
+
+// Error returned by Error and Critic for non-error values, formatted only when used
+type valueError struct {
+	value interface{}
+}
+
+func (err valueError) Error() string {
+	return fmt.Sprintf("%v", err.value)
+}
+
+// Returns value itself if it is an error, so callers can still match it with
+// errors.Is. A nil pointer error is wrapped, since its Error method could panic.
+func asError(value interface{}) error {
+	if err, ok := value.(error); ok && !isNil(err) {
+		return err
+	}
+	if isNil(value) {
+		return valueError{"nil"}
+	}
+	return valueError{value}
+}
+
+// Implemented by errors carrying tags, which are merged into the record when logged
+type TaggedError interface {
+	error
+	Tags() Tags
+}
+
+type taggedError struct {
+	msg  string
+	tags Tags
+}
+
+// Returns an error carrying tags, merged into the record whenever it is logged
+// (also when wrapped with fmt.Errorf("...: %w", err) in another layer)
+func NewError(msg string, tags Tags) error {
+	return taggedError{msg, tags}
+}
+
+func (err taggedError) Error() string {
+	return err.msg
+}
+
+func (err taggedError) Tags() Tags {
+	return err.tags
+}
+
+// Merges the tags of every TaggedError in the chain of err, outer ones winning
+func errorTags(err error) Tags {
+	var chain []Tags
+	for ; err != nil; err = errors.Unwrap(err) {
+		if tagged, ok := err.(TaggedError); ok {
+			chain = append(chain, tagged.Tags())
+		}
+	}
+	tags := Tags{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		tags = tags.merge(chain[i])
+	}
+	return tags
+}
+
+// Prepends the tags derived from the logged error value to eventsAndTags, so tags
+// given at the call site win. The error class is also added to the metric tags.
+func withErrorTags(value interface{}, eventsAndTags []interface{}) []interface{} {
+	if isNil(value) {
+		return eventsAndTags
+	}
+	extra := []interface{}{}
+	if tags := multiErrorTags(value); tags != nil {
+		extra = append(extra, tags)
+	}
+	if err, ok := value.(error); ok {
+		if tags := errorTags(err); len(tags) > 0 {
+			extra = append(extra, tags)
+		}
+		if class := ClassifyError(err); class != "" {
+			extra = append(extra, Tags{"class": class}, metrics.Tags{"class": class})
+		}
+	}
+	if len(extra) == 0 {
+		return eventsAndTags
+	}
+	return append(extra, eventsAndTags...)
+}
+
+// Returns the constituent errors of a joined (errors.Join) or otherwise
+// multi-error value, possibly wrapped, as indexed "error.N" tags plus their count
+func multiErrorTags(value interface{}) Tags {
+	err, ok := value.(error)
+	if !ok {
+		return nil
+	}
+	errs := unwrapMulti(err)
+	if len(errs) == 0 {
+		return nil
+	}
+	tags := Tags{"error_count": len(errs)}
+	for i, e := range errs {
+		tags[fmt.Sprintf("error.%d", i)] = e.Error()
+	}
+	return tags
+}
+
+func unwrapMulti(err error) []error {
+	for err != nil {
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			return multi.Unwrap()
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
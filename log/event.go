@@ -0,0 +1,78 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Name of the event of a record, accepted in eventsAndTags like a plain string
+type Event string
+
+// Strictness of the event schema checks
+const (
+	EVENTS_LAX   = 0 // No checks
+	EVENTS_WARN  = 1 // Log a warning for records not matching the schema of their event
+	EVENTS_ERROR = 2 // Log an error for records not matching the schema of their event
+)
+
+var eventSchemas = map[string]map[string]bool{}
+var eventSchemasLock sync.RWMutex
+var eventStrictness = EVENTS_LAX
+
+// Declares the tags expected on the records of an event
+func RegisterEvent(name string, expectedTags ...string) Event {
+	schema := make(map[string]bool, len(expectedTags))
+	for _, tag := range expectedTags {
+		schema[tag] = true
+	}
+	eventSchemasLock.Lock()
+	defer eventSchemasLock.Unlock()
+	eventSchemas[name] = schema
+	return Event(name)
+}
+
+func SetEventStrictness(strictness int) {
+	eventStrictness = strictness
+}
+
+// Returns the missing and unexpected tags of a record of a registered event,
+// given the tags passed along with the event
+func checkEventSchema(event string, callTags []string) (missing []string, unexpected []string) {
+	eventSchemasLock.RLock()
+	schema, ok := eventSchemas[event]
+	eventSchemasLock.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	present := make(map[string]bool, len(callTags))
+	for _, tag := range callTags {
+		present[tag] = true
+		if !schema[tag] {
+			unexpected = append(unexpected, tag)
+		}
+	}
+	for tag := range schema {
+		if !present[tag] {
+			missing = append(missing, tag)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
+
+func (context logContext) validateEvent(event string, callTags []string) {
+	missing, unexpected := checkEventSchema(event, callTags)
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return
+	}
+	message := fmt.Sprintf("Event %s does not match its schema", event)
+	tags := Tags{"schema_event": event, "missing_tags": strings.Join(missing, ","), "unexpected_tags": strings.Join(unexpected, ",")}
+	if eventStrictness == EVENTS_ERROR {
+		context.Error(message, "event_schema_violation", tags)
+	} else {
+		context.Warn(message, "event_schema_violation", tags)
+	}
+}
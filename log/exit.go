@@ -0,0 +1,91 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+)
+
+// Exit codes for common fatal categories, following sysexits.h
+const (
+	EXIT_RUNTIME     = 1
+	EXIT_USAGE       = 64
+	EXIT_UNAVAILABLE = 69
+	EXIT_SOFTWARE    = 70
+	EXIT_IO          = 74
+	EXIT_CONFIG      = 78
+)
+
+var exitFunc = os.Exit
+
+var fatalHooks []func(record Tags)
+var fatalHooksLock sync.Mutex
+
+// Replaces os.Exit as the function called by Fatalf, so fatal paths can be
+// tested. Fatalf returns if the given function does. nil restores os.Exit.
+func SetExitFunc(f func(code int)) {
+	if f == nil {
+		f = os.Exit
+	}
+	exitFunc = f
+}
+
+// Registers a hook run before the process exits on Fatal or on a recovered panic,
+// e.g. to close connections, flush traces or write crash marker files.
+// Hooks run in registration order; a panicking hook does not prevent the exit.
+func OnFatal(hook func(record Tags)) {
+	fatalHooksLock.Lock()
+	defer fatalHooksLock.Unlock()
+	fatalHooks = append(fatalHooks, hook)
+}
+
+func runFatalHooks(record Tags) {
+	fatalHooksLock.Lock()
+	hooks := append([]func(Tags){}, fatalHooks...)
+	fatalHooksLock.Unlock()
+	for _, hook := range hooks {
+		runFatalHook(hook, record)
+	}
+}
+
+func runFatalHook(hook func(Tags), record Tags) {
+	defer func() {
+		if r := recover(); r != nil {
+			stderr.Logf("error", "Fatal hook panicked: %v", r)
+		}
+	}()
+	hook(record)
+}
+
+// Logs a fatal record tagged with the exit code and exits with it, so orchestrators
+// can distinguish e.g. configuration errors (EXIT_CONFIG) from runtime crashes
+func (context logContext) FatalCode(code int, value interface{}, eventsAndTags ...interface{}) {
+	context.fatal(code, fmt.Errorf("%v", value), eventsAndTags...)
+}
+
+func FatalCode(code int, value interface{}, eventsAndTags ...interface{}) {
+	defaultContext.FatalCode(code, value, eventsAndTags...)
+}
+
+// Logs the fatal record (if the level is enabled), runs the fatal hooks and exits
+func (context logContext) fatal(code int, err error, eventsAndTags ...interface{}) {
+	message := err.Error()
+	record := context.fields.tags().merge(Tags{"level": "fatal", "message": message, "exit_code": code})
+	if context.enabled(FATAL) {
+		if logged := context.log("fatal", message, err, append(eventsAndTags, Tags{"exit_code": code})...); logged != nil {
+			record = logged
+		}
+	}
+	runFatalHooks(record)
+	exit(code)
+}
+
+// Flushes error reporters, output and sinks before exiting
+func exit(code int) {
+	flushReporters()
+	flushOutput()
+	FlushSinks()
+	exitFunc(code)
+}
@@ -0,0 +1,39 @@
+package log
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// Append-only list of tags, where later fields override earlier ones with the
+// same key. Contexts never append in place, so derived contexts can share it.
+type fields []field
+
+// Returns a copy of f followed by tags
+func (f fields) with(tags Tags) fields {
+	if len(tags) == 0 {
+		return f
+	}
+	return f[:len(f):len(f)].appendTags(tags)
+}
+
+func (f fields) appendTags(tags Tags) fields {
+	for k, v := range tags {
+		f = serialize(f, k, v)
+	}
+	return f
+}
+
+func (f fields) tags() Tags {
+	tags := make(Tags, len(f))
+	for _, field := range f {
+		if g, ok := field.value.(group); ok {
+			if prev, ok := tags[field.key].(group); ok {
+				tags[field.key] = prev.merge(g)
+				continue
+			}
+		}
+		tags[field.key] = field.value
+	}
+	return tags
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Decides whether a record is emitted, returning false to suppress it
+type Filter func(record Tags) bool
+
+var filters []Filter
+var filtersLock sync.RWMutex
+
+// Registers a filter applied to the standard output and to every sink not
+// wrapped with Unfiltered
+func AddFilter(filter Filter) {
+	filtersLock.Lock()
+	defer filtersLock.Unlock()
+	filters = append(filters, filter)
+}
+
+// Removes every filter registered with AddFilter
+func ClearFilters() {
+	filtersLock.Lock()
+	defer filtersLock.Unlock()
+	filters = nil
+}
+
+func passesFilters(record Tags) bool {
+	filtersLock.RLock()
+	defer filtersLock.RUnlock()
+	for _, filter := range filters {
+		if !filter(record) {
+			return false
+		}
+	}
+	return true
+}
+
+// Keeps records at level min or above. Records of unknown levels are kept.
+func LevelFilter(min int) Filter {
+	return func(record Tags) bool {
+		level, ok := levelNames[strings.ToUpper(fmt.Sprintf("%v", record["level"]))]
+		return !ok || level >= min
+	}
+}
+
+// Suppresses the records of the given events
+func DropEvents(events ...string) Filter {
+	dropped := make(map[string]bool, len(events))
+	for _, event := range events {
+		dropped[event] = true
+	}
+	return func(record Tags) bool {
+		event, ok := record["event"]
+		return !ok || !dropped[fmt.Sprintf("%v", event)]
+	}
+}
+
+// Keeps the records whose tag key is present and satisfies predicate
+func TagFilter(key string, predicate func(value interface{}) bool) Filter {
+	return func(record Tags) bool {
+		value, ok := record[key]
+		return ok && predicate(value)
+	}
+}
+
+// Keeps the records whose message matches pattern
+func MessageFilter(pattern *regexp.Regexp) Filter {
+	return func(record Tags) bool {
+		return pattern.MatchString(fmt.Sprintf("%v", record["message"]))
+	}
+}
+
+func Not(filter Filter) Filter {
+	return func(record Tags) bool {
+		return !filter(record)
+	}
+}
+
+type filteredSink struct {
+	sink    Sink
+	filters []Filter
+}
+
+// Wraps sink so it only receives the records passing every filter
+func FilteredSink(sink Sink, filters ...Filter) Sink {
+	return &filteredSink{sink, filters}
+}
+
+func (f *filteredSink) Write(record Tags) error {
+	for _, filter := range f.filters {
+		if !filter(record) {
+			return nil
+		}
+	}
+	return f.sink.Write(record)
+}
+
+func (f *filteredSink) Flush() error {
+	if flusher, ok := f.sink.(sinkFlusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (f *filteredSink) Close() error {
+	if c, ok := f.sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type unfilteredSink struct {
+	Sink
+}
+
+// Wraps sink so it receives every record, regardless of the filters registered
+// with AddFilter, e.g. a debug file keeping the records suppressed elsewhere
+func Unfiltered(sink Sink) Sink {
+	return &unfilteredSink{sink}
+}
+
+func (u *unfilteredSink) Flush() error {
+	if flusher, ok := u.Sink.(sinkFlusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (u *unfilteredSink) Close() error {
+	if c, ok := u.Sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
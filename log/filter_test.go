@@ -0,0 +1,86 @@
+package log
+
+import (
+	"io"
+	"regexp"
+	"testing"
+)
+
+// Sink recording whether it was closed
+type closingSink struct {
+	recordingSink
+	closed bool
+}
+
+func (sink *closingSink) Close() error {
+	sink.closed = true
+	return nil
+}
+
+func TestFilters(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter Filter
+		record Tags
+		keep   bool
+	}{
+		{"level below", LevelFilter(WARN), Tags{"level": "info"}, false},
+		{"level at", LevelFilter(WARN), Tags{"level": "warn"}, true},
+		{"unknown level", LevelFilter(WARN), Tags{"level": "notice"}, true},
+		{"dropped event", DropEvents("health_check"), Tags{"event": "health_check"}, false},
+		{"other event", DropEvents("health_check"), Tags{"event": "order.created"}, true},
+		{"no event", DropEvents("health_check"), Tags{}, true},
+		{"tag missing", TagFilter("user", func(interface{}) bool { return true }), Tags{}, false},
+		{"tag rejected", TagFilter("status", func(v interface{}) bool { return v.(int) >= 500 }), Tags{"status": 404}, false},
+		{"tag accepted", TagFilter("status", func(v interface{}) bool { return v.(int) >= 500 }), Tags{"status": 503}, true},
+		{"message matched", MessageFilter(regexp.MustCompile("^retry")), Tags{"message": "retrying"}, true},
+		{"message not matched", MessageFilter(regexp.MustCompile("^retry")), Tags{"message": "done"}, false},
+		{"negated", Not(DropEvents("health_check")), Tags{"event": "health_check"}, true},
+	}
+	for _, c := range cases {
+		if keep := c.filter(c.record); keep != c.keep {
+			t.Errorf("%s: expected %v, got %v", c.name, c.keep, keep)
+		}
+	}
+}
+
+func TestFiltersExceptUnfilteredSink(t *testing.T) {
+	withLevel(t, TRACE)
+	AddFilter(DropEvents("health_check"))
+	defer ClearFilters()
+	filtered, debug := &recordingSink{}, &recordingSink{}
+	unfiltered := Unfiltered(debug)
+	AddSink(filtered)
+	AddSink(unfiltered)
+	defer RemoveSink(filtered)
+	defer RemoveSink(unfiltered)
+
+	Info("Healthy", "health_check", nil)
+	Info("Created", "order.created", nil)
+	if len(filtered.records) != 1 || filtered.records[0]["event"] != "order.created" {
+		t.Errorf("Expected only order.created on the filtered sink, got %v", filtered.records)
+	}
+	if len(debug.records) != 2 {
+		t.Errorf("Expected both records on the unfiltered sink, got %v", debug.records)
+	}
+}
+
+func TestFilteredSinksClose(t *testing.T) {
+	for name, wrap := range map[string]func(Sink) Sink{
+		"filtered":   func(sink Sink) Sink { return FilteredSink(sink, LevelFilter(WARN)) },
+		"unfiltered": Unfiltered,
+	} {
+		sink := &closingSink{}
+		closer, ok := wrap(sink).(io.Closer)
+		if !ok {
+			t.Errorf("%s: not a closer", name)
+			continue
+		}
+		if err := closer.Close(); err != nil || !sink.closed {
+			t.Errorf("%s: expected the wrapped sink closed, got %v", name, err)
+		}
+	}
+	if err := Unfiltered(&recordingSink{}).(io.Closer).Close(); err != nil {
+		t.Errorf("Expected closing a sink that is not a closer to succeed, got %s", err)
+	}
+}
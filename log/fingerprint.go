@@ -0,0 +1,38 @@
+package log
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"runtime"
+)
+
+var templateMasks = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\b(0x[0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`), "<hex>"},
+	{regexp.MustCompile(`\d+(\.\d+)?`), "<num>"},
+}
+
+// Returns message with its variable parts (quoted strings, UUIDs, hex and
+// numbers) masked, so occurrences of the same defect share a template
+func MessageTemplate(message string) string {
+	for _, mask := range templateMasks {
+		message = mask.pattern.ReplaceAllString(message, mask.replacement)
+	}
+	return message
+}
+
+// Returns a short hash of the message template and the function of the top
+// stack frame, used to group occurrences of the same defect
+func Fingerprint(message string, stack []runtime.Frame) string {
+	hash := sha1.New()
+	hash.Write([]byte(MessageTemplate(message)))
+	if len(stack) > 0 {
+		hash.Write([]byte("|" + stack[0].Function))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
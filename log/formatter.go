@@ -0,0 +1,197 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	stdlog "log"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+// Encodes records into lines (without the trailing newline)
+type Formatter interface {
+	Format(buf *bytes.Buffer, record Tags)
+}
+
+// Implemented by formatters that can encode a record tag by tag, which lets
+// contexts encode their constant tags once and reuse them
+type tagFormatter interface {
+	Formatter
+	Start(buf *bytes.Buffer)
+	// n is the number of tags already written in the record
+	AppendTag(buf *bytes.Buffer, n int, key string, value interface{})
+	End(buf *bytes.Buffer)
+}
+
+var formatter Formatter = BracketsFormatter{}
+
+// Incremented by SetFormatter, so encoded contexts notice the change without
+// comparing formatters, which panics for non comparable ones
+var formatterGeneration uint64
+
+var formatters = map[string]Formatter{
+	"brackets": BracketsFormatter{},
+	"ecs":      ECSFormatter{},
+	"json":     JSONFormatter{},
+	"logfmt":   LogfmtFormatter{},
+	"pretty":   PrettyFormatter{},
+	"stdlib":   StdlibFormatter{Flags: stdlog.LstdFlags},
+}
+
+func SetFormatter(f Formatter) {
+	formatter = f
+	atomic.AddUint64(&formatterGeneration, 1)
+}
+
+// Returns the formatter named brackets (the default), ecs, json, logfmt, pretty
+// or stdlib (StdlibFormatter with log.LstdFlags)
+func FormatterByName(name string) (Formatter, error) {
+	f, ok := formatters[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("Invalid log format: %s", name)
+	}
+	return f, nil
+}
+
+func formatTags(f tagFormatter, buf *bytes.Buffer, record Tags) {
+	f.Start(buf)
+	n := 0
+	for k, v := range record {
+		f.AppendTag(buf, n, k, v)
+		n++
+	}
+	f.End(buf)
+}
+
+// [key:value][key:value]...
+type BracketsFormatter struct{}
+
+func (f BracketsFormatter) Format(buf *bytes.Buffer, record Tags) {
+	formatTags(f, buf, record)
+}
+
+func (BracketsFormatter) Start(buf *bytes.Buffer) {}
+
+func (f BracketsFormatter) AppendTag(buf *bytes.Buffer, n int, k string, v interface{}) {
+	if g, ok := v.(group); ok {
+		g.each(k+".", func(key string, value interface{}) {
+			f.AppendTag(buf, n, key, value)
+		})
+		return
+	}
+	buf.WriteByte('[')
+	buf.WriteString(k)
+	buf.WriteByte(':')
+	if str, ok := renderValue(v).(string); ok {
+		buf.WriteString(str)
+	} else {
+		fmt.Fprintf(buf, "%+v", v)
+	}
+	buf.WriteByte(']')
+}
+
+func (BracketsFormatter) End(buf *bytes.Buffer) {}
+
+// {"key":value,...}
+type JSONFormatter struct{}
+
+func (f JSONFormatter) Format(buf *bytes.Buffer, record Tags) {
+	formatTags(f, buf, record)
+}
+
+func (JSONFormatter) Start(buf *bytes.Buffer) {
+	buf.WriteByte('{')
+}
+
+func (JSONFormatter) AppendTag(buf *bytes.Buffer, n int, k string, v interface{}) {
+	if n > 0 {
+		buf.WriteByte(',')
+	}
+	key, _ := json.Marshal(k)
+	buf.Write(key)
+	buf.WriteByte(':')
+	buf.Write(jsonValue(v))
+}
+
+func (JSONFormatter) End(buf *bytes.Buffer) {
+	buf.WriteByte('}')
+}
+
+func jsonValue(v interface{}) []byte {
+	v = renderValue(v)
+	switch v.(type) {
+	case string, bool, int, int64, float64, nil:
+	default:
+		v = jsonTree(v)
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		value, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	return value
+}
+
+// key=value key="quoted value"...
+type LogfmtFormatter struct{}
+
+func (f LogfmtFormatter) Format(buf *bytes.Buffer, record Tags) {
+	formatTags(f, buf, record)
+}
+
+func (LogfmtFormatter) Start(buf *bytes.Buffer) {}
+
+func (f LogfmtFormatter) AppendTag(buf *bytes.Buffer, n int, k string, v interface{}) {
+	if g, ok := v.(group); ok {
+		g.each(k+".", func(key string, value interface{}) {
+			f.AppendTag(buf, n, key, value)
+			n++
+		})
+		return
+	}
+	if n > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(k)
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(v))
+}
+
+func (LogfmtFormatter) End(buf *bytes.Buffer) {}
+
+func logfmtValue(v interface{}) string {
+	str, ok := renderValue(v).(string)
+	if !ok {
+		str = fmt.Sprintf("%+v", v)
+	}
+	if str == "" || strings.ContainsAny(str, " =\"\n\t") {
+		return fmt.Sprintf("%q", str)
+	}
+	return str
+}
+
+// Human friendly format for local development:
+// 15:04:05.000 INFO  message key=value ...
+type PrettyFormatter struct{}
+
+func (PrettyFormatter) Format(buf *bytes.Buffer, record Tags) {
+	record = flattenGroups(record)
+	buf.WriteString(clock.Timestamp().Format("15:04:05.000"))
+	fmt.Fprintf(buf, " %-6s %v", strings.ToUpper(fmt.Sprintf("%v", record["level"])), renderValue(record["message"]))
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		if k != "level" && k != "message" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteByte(' ')
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(record[k]))
+	}
+}
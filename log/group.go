@@ -0,0 +1,87 @@
+package log
+
+import "sort"
+
+// Tags nested under a group name. JSON renders them as a nested object and the
+// flat formats as "group.key" tags.
+type group Tags
+
+func WithGroup(name string) logContext {
+	return defaultContext.WithGroup(name)
+}
+
+// Returns a context whose tags added afterwards, with WithContext or on each
+// record, are nested under name (after the groups already opened), keeping
+// large contexts organized and free of key collisions:
+//
+//	log.WithGroup("db").Info("Query", log.Tags{"table": "users"}) // db.table=users
+func (context logContext) WithGroup(name string) logContext {
+	if name == "" {
+		return context
+	}
+	context.group = append(context.group[:len(context.group):len(context.group)], name)
+	return context
+}
+
+// Appends tags nested under the groups of path, if any
+func (f fields) appendGroup(path []string, tags Tags) fields {
+	if len(path) == 0 {
+		return f.appendTags(tags)
+	}
+	if len(tags) == 0 {
+		return f
+	}
+	nested := group(fields(nil).appendTags(tags).tags())
+	for i := len(path) - 1; i > 0; i-- {
+		nested = group{path[i]: nested}
+	}
+	return append(f, field{path[0], nested})
+}
+
+// Returns the tags of both groups, those of other overriding those of g
+func (g group) merge(other group) group {
+	merged := make(group, len(g)+len(other))
+	for k, v := range g {
+		merged[k] = v
+	}
+	for k, v := range other {
+		if nested, ok := v.(group); ok {
+			if prev, ok := merged[k].(group); ok {
+				v = prev.merge(nested)
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// Calls fn with the dotted key of every tag of g, sorted
+func (g group) each(prefix string, fn func(key string, value interface{})) {
+	keys := make([]string, 0, len(g))
+	for k := range g {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if nested, ok := g[k].(group); ok {
+			nested.each(prefix+k+".", fn)
+		} else {
+			fn(prefix+k, g[k])
+		}
+	}
+}
+
+// Returns record with its groups replaced by "group.key" tags
+func flattenGroups(record Tags) Tags {
+	flat := make(Tags, len(record))
+	for k, v := range record {
+		if g, ok := v.(group); ok {
+			g.each(k+".", func(key string, value interface{}) {
+				flat[key] = value
+			})
+		} else {
+			flat[k] = v
+		}
+	}
+	return flat
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Sink keeping the records written to it
+type recordingSink struct {
+	records []Tags
+}
+
+func (sink *recordingSink) Write(record Tags) error {
+	sink.records = append(sink.records, record)
+	return nil
+}
+
+// Returns the records whose key tag is value
+func (sink *recordingSink) where(key string, value interface{}) []Tags {
+	var records []Tags
+	for _, record := range sink.records {
+		if record[key] == value {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func recordingContext() (logContext, *recordingSink) {
+	return recordingContextAt(TRACE)
+}
+
+// Returns a context writing the records of level and above to the sink
+func recordingContextAt(level int) (logContext, *recordingSink) {
+	sink := &recordingSink{}
+	context := defaultContext
+	context.output = sink
+	context.level = &level
+	return context, sink
+}
+
+// Clock standing still at now
+type stoppedClock struct {
+	now time.Time
+}
+
+func (c *stoppedClock) Now() time.Time {
+	return c.now
+}
+
+func (c *stoppedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Stops the clock at now until the test ends
+func useStoppedClock(tb testing.TB, now time.Time) *stoppedClock {
+	c := &stoppedClock{now}
+	clock.Set(c)
+	tb.Cleanup(func() { clock.Set(nil) })
+	return c
+}
+
+// Metrics backend keeping what is pushed as "<name> <value> <sorted tags>"
+type metricsBackend struct {
+	lock    sync.Mutex
+	metrics []string
+}
+
+func (b *metricsBackend) Record(metricType string, name string, value float64, tags []string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	b.metrics = append(b.metrics, fmt.Sprintf("%s %v %s", strings.TrimPrefix(name, "."), value, strings.Join(sorted, ",")))
+	return nil
+}
+
+func (b *metricsBackend) recorded() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]string(nil), b.metrics...)
+}
+
+func (b *metricsBackend) reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.metrics = nil
+}
+
+// Pushes the metrics of the records to a recording backend until the test ends
+func usePushedMetrics(tb testing.TB) *metricsBackend {
+	backend := &metricsBackend{}
+	push := pushMetrics
+	pushMetrics = true
+	metrics.UseBackend(backend)
+	tb.Cleanup(func() {
+		pushMetrics = push
+		metrics.UseBackend(nil)
+	})
+	return backend
+}
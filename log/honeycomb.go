@@ -0,0 +1,42 @@
+package log
+
+import (
+	"fmt"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+// Sink sending each record as an event to Honeycomb's events API
+type HoneycombSink struct {
+	client *libhoney.Client
+}
+
+func NewHoneycombSink(apiKey string, dataset string) (*HoneycombSink, error) {
+	client, err := libhoney.NewClient(libhoney.ClientConfig{APIKey: apiKey, Dataset: dataset})
+	if err != nil {
+		return nil, fmt.Errorf("Could not create honeycomb client: %s", err)
+	}
+	return &HoneycombSink{client}, nil
+}
+
+func (sink *HoneycombSink) Write(record Tags) error {
+	event := sink.client.NewEvent()
+	event.Timestamp = clock.Now()
+	for k, v := range record {
+		event.AddField(k, v)
+	}
+	return event.Send()
+}
+
+// Sends the buffered events
+func (sink *HoneycombSink) Flush() error {
+	sink.client.Flush()
+	return nil
+}
+
+// Sends the buffered events and stops the client
+func (sink *HoneycombSink) Close() error {
+	sink.client.Close()
+	return nil
+}
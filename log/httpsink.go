@@ -0,0 +1,351 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+const (
+	defaultHTTPBatchSize     = 100
+	defaultHTTPQueueSize     = 10000
+	defaultHTTPFlushInterval = 5 * time.Second
+	defaultHTTPTimeout       = 10 * time.Second
+	defaultHTTPRetries       = 3
+)
+
+// Configuration of HTTPSink
+type HTTPSinkConfig struct {
+	URL string
+	// Added to every request, typically the credentials of the ingestion API
+	Headers map[string]string
+	// Records sent per request, 100 by default
+	BatchSize int
+	// Time a batch waits to fill before being sent anyway, 5s by default
+	FlushInterval time.Duration
+	// Records queued while the endpoint is slow or down, 10000 by default
+	QueueSize int
+	// What Write does when the queue is full: BLOCK, DROP_NEWEST or DROP_OLDEST
+	// (the default), as for AsyncSink
+	Policy string
+	// Attempts after a failed request (network error, 429 or 5xx): 0 for none,
+	// negative for the default of 3
+	Retries int
+	// Sends the body uncompressed instead of gzipped
+	DisableCompression bool
+	// Defaults to a client with a 10s timeout
+	Client *http.Client
+	// Encodes a batch as the request body. Defaults to one line per record
+	// formatted with Formatter (JSONFormatter by default).
+	Encode      func(records []Tags) ([]byte, error)
+	Formatter   Formatter
+	ContentType string // "application/x-ndjson" by default
+	// Called on each request once its body is set, to add headers derived from it
+	// such as signatures
+	Sign func(req *http.Request, body []byte) error
+}
+
+// Sink POSTing batches of records to an HTTP endpoint from a goroutine, gzipped
+// and retried with exponential backoff. Base of the SaaS ingestion sinks.
+type HTTPSink struct {
+	config    HTTPSinkConfig
+	lock      sync.Mutex
+	room      *sync.Cond // Broadcast when records leave the queue
+	sent      *sync.Cond // Broadcast when a batch is done
+	records   []Tags
+	sending   int
+	dropped   int
+	batches   int
+	lastError error
+	closed    bool
+	wake      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewHTTPSink(config HTTPSinkConfig) *HTTPSink {
+	switch config.Policy {
+	case "":
+		config.Policy = DROP_OLDEST
+	case BLOCK, DROP_NEWEST, DROP_OLDEST:
+	default:
+		panic(fmt.Sprintf("Invalid overflow policy: %s", config.Policy))
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultHTTPBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultHTTPFlushInterval
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultHTTPQueueSize
+	}
+	if config.QueueSize < config.BatchSize {
+		config.QueueSize = config.BatchSize
+	}
+	if config.Retries < 0 {
+		config.Retries = defaultHTTPRetries
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if config.Formatter == nil {
+		config.Formatter = JSONFormatter{}
+	}
+	if config.Encode == nil {
+		config.Encode = ndjsonEncoder(config.Formatter)
+		if config.ContentType == "" {
+			config.ContentType = "application/x-ndjson"
+		}
+	}
+	sink := &HTTPSink{config: config, wake: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	sink.room = sync.NewCond(&sink.lock)
+	sink.sent = sync.NewCond(&sink.lock)
+	go sink.run()
+	return sink
+}
+
+// Returns an encoder writing one formatted record per line
+func ndjsonEncoder(formatter Formatter) func(records []Tags) ([]byte, error) {
+	return func(records []Tags) ([]byte, error) {
+		buf := new(bytes.Buffer)
+		for _, record := range records {
+			formatter.Format(buf, record)
+			if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// Returns an encoder writing the records as a JSON array
+func jsonArrayEncoder(records []Tags) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('[')
+	for i, record := range records {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		JSONFormatter{}.Format(buf, record)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// Returns a copy of record tagged with the time it was logged, since records
+// reach ingestion APIs seconds later
+func stamped(record Tags, key string, value interface{}) Tags {
+	return record.merge(Tags{key: value})
+}
+
+func (sink *HTTPSink) Write(record Tags) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if sink.closed {
+		return fmt.Errorf("Sink closed")
+	}
+	if len(sink.records) >= sink.config.QueueSize {
+		switch sink.config.Policy {
+		case BLOCK:
+			for len(sink.records) >= sink.config.QueueSize && !sink.closed {
+				sink.room.Wait()
+			}
+			if sink.closed {
+				return fmt.Errorf("Sink closed")
+			}
+		case DROP_NEWEST:
+			sink.dropped++
+			return fmt.Errorf("Queue full, record dropped")
+		default:
+			// Resliced, so append reallocates only once the queue moved to the end
+			// of its array, copying the queued records alone
+			sink.dropped++
+			sink.records[0] = nil
+			sink.records = sink.records[1:]
+		}
+	}
+	sink.records = append(sink.records, record)
+	if len(sink.records) >= sink.config.BatchSize {
+		sink.signal()
+	}
+	return nil
+}
+
+// Wakes up the sending goroutine
+func (sink *HTTPSink) signal() {
+	select {
+	case sink.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (sink *HTTPSink) run() {
+	defer close(sink.done)
+	for {
+		select {
+		case <-sink.wake:
+		case <-clock.After(sink.config.FlushInterval):
+		case <-sink.stop:
+			return
+		}
+		for {
+			batch := sink.take()
+			if len(batch) == 0 {
+				break
+			}
+			err := sink.post(batch)
+			sink.lock.Lock()
+			sink.sending = 0
+			if err != nil {
+				sink.dropped += len(batch)
+				sink.lastError = err
+			} else {
+				sink.batches++
+			}
+			sink.sent.Broadcast()
+			sink.lock.Unlock()
+		}
+	}
+}
+
+// Removes the next batch from the queue
+func (sink *HTTPSink) take() []Tags {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	n := len(sink.records)
+	if n > sink.config.BatchSize {
+		n = sink.config.BatchSize
+	}
+	batch := sink.records[:n:n]
+	sink.records = sink.records[n:]
+	sink.sending = n
+	sink.room.Broadcast()
+	return batch
+}
+
+// Sends batch, retrying network errors, 429 and 5xx responses
+func (sink *HTTPSink) post(batch []Tags) error {
+	body, err := sink.config.Encode(batch)
+	if err != nil {
+		return fmt.Errorf("Could not encode %d records: %s", len(batch), err)
+	}
+	if !sink.config.DisableCompression {
+		buf := new(bytes.Buffer)
+		zw := gzip.NewWriter(buf)
+		zw.Write(body)
+		zw.Close()
+		body = buf.Bytes()
+	}
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		if retry, err = sink.request(body); err == nil || !retry || attempt >= sink.config.Retries {
+			return err
+		}
+		select {
+		case <-clock.After(backoff):
+		case <-sink.stop:
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// Returns whether a failed request may succeed if retried
+func (sink *HTTPSink) request(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, sink.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if sink.config.ContentType != "" {
+		req.Header.Set("Content-Type", sink.config.ContentType)
+	}
+	if !sink.config.DisableCompression {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for name, value := range sink.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if sink.config.Sign != nil {
+		if err := sink.config.Sign(req, body); err != nil {
+			return false, fmt.Errorf("Could not sign request: %s", err)
+		}
+	}
+	resp, err := sink.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%s responded %s", sink.config.URL, resp.Status)
+}
+
+// Sends the queued records, waiting up to StreamFlushTimeout
+func (sink *HTTPSink) Flush() error {
+	timedOut := false
+	flushed := make(chan struct{})
+	defer close(flushed)
+	timeout := clock.After(StreamFlushTimeout)
+	go func() {
+		select {
+		case <-timeout:
+			sink.lock.Lock()
+			timedOut = true
+			sink.sent.Broadcast()
+			sink.lock.Unlock()
+		case <-flushed:
+		}
+	}()
+	sink.signal()
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	dropped := sink.dropped
+	for len(sink.records)+sink.sending > 0 {
+		if timedOut {
+			return fmt.Errorf("%d records not sent: %v", len(sink.records)+sink.sending, sink.lastError)
+		}
+		sink.sent.Wait()
+	}
+	if sink.dropped > dropped {
+		return fmt.Errorf("%d records dropped: %v", sink.dropped-dropped, sink.lastError)
+	}
+	return nil
+}
+
+// Sends the queued records and stops the goroutine
+func (sink *HTTPSink) Close() error {
+	sink.lock.Lock()
+	if sink.closed {
+		sink.lock.Unlock()
+		return nil
+	}
+	sink.closed = true
+	sink.room.Broadcast()
+	sink.lock.Unlock()
+	err := sink.Flush()
+	close(sink.stop)
+	<-sink.done
+	return err
+}
+
+func (sink *HTTPSink) Status() Tags {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	status := Tags{"queued": len(sink.records) + sink.sending, "capacity": sink.config.QueueSize, "policy": sink.config.Policy,
+		"batches": sink.batches, "dropped": sink.dropped}
+	if sink.lastError != nil {
+		status["last_error"] = sink.lastError.Error()
+	}
+	return status
+}
@@ -0,0 +1,149 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Endpoint answering with the given statuses in turn, then 200, and keeping the
+// lines of the bodies received
+type ingestServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	statuses []int
+	requests int
+	lines    []string
+	headers  []http.Header
+}
+
+func newIngestServer(statuses ...int) *ingestServer {
+	server := &ingestServer{statuses: statuses}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.lock.Lock()
+		defer server.lock.Unlock()
+		server.requests++
+		server.headers = append(server.headers, r.Header)
+		if len(server.statuses) > 0 {
+			status := server.statuses[0]
+			server.statuses = server.statuses[1:]
+			w.WriteHeader(status)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			server.lines = append(server.lines, scanner.Text())
+		}
+	}))
+	return server
+}
+
+func TestHTTPSinkRetries(t *testing.T) {
+	cases := []struct {
+		name     string
+		retries  int
+		statuses []int
+		requests int
+		sent     bool
+	}{
+		{"ok", -1, nil, 1, true},
+		{"server error", -1, []int{500, 503}, 3, true},
+		{"throttled", -1, []int{429}, 2, true},
+		{"bad request", -1, []int{400}, 1, false},
+		{"retries exhausted", -1, []int{500, 500, 500, 500}, 4, false},
+		{"no retries", 0, []int{500}, 1, false},
+		{"one retry", 1, []int{500, 500}, 2, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := newIngestServer(c.statuses...)
+			defer server.Close()
+			sink := NewHTTPSink(HTTPSinkConfig{URL: server.URL, Headers: map[string]string{"Api-Key": "secret"}, Retries: c.retries})
+			sink.Write(Tags{"message": "one"})
+			sink.Write(Tags{"message": "two"})
+			err := sink.Close()
+			if c.sent != (err == nil) {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if server.requests != c.requests {
+				t.Errorf("Expected %d requests, got %d", c.requests, server.requests)
+			}
+			if c.sent && (len(server.lines) != 2 || !strings.Contains(server.lines[1], `"message":"two"`)) {
+				t.Errorf("Unexpected lines %v", server.lines)
+			}
+			if key := server.headers[0].Get("Api-Key"); key != "secret" {
+				t.Errorf("Unexpected Api-Key %s", key)
+			}
+		})
+	}
+}
+
+func TestHTTPSinkBatches(t *testing.T) {
+	server := newIngestServer()
+	defer server.Close()
+	sink := NewHTTPSink(HTTPSinkConfig{URL: server.URL, BatchSize: 2, FlushInterval: time.Hour, DisableCompression: true})
+	defer sink.Close()
+	for _, message := range []string{"a", "b", "c"} {
+		sink.Write(Tags{"message": message})
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if server.requests != 2 || len(server.lines) != 3 {
+		t.Errorf("Expected 3 lines in 2 requests, got %d in %d", len(server.lines), server.requests)
+	}
+	if encoding := server.headers[0].Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Unexpected Content-Encoding %s", encoding)
+	}
+}
+
+func TestHTTPSinkOverflow(t *testing.T) {
+	cases := []struct {
+		policy   string
+		err      bool
+		expected string
+	}{
+		{DROP_OLDEST, false, "c"},
+		{DROP_NEWEST, true, "b"},
+	}
+	for _, c := range cases {
+		received := make(chan string)
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- string(body)
+			<-release
+		}))
+		sink := NewHTTPSink(HTTPSinkConfig{URL: server.URL, BatchSize: 1, QueueSize: 1, Policy: c.policy, DisableCompression: true})
+		sink.Write(Tags{"message": "a"})
+		<-received // Being sent, so the queue is empty
+		sink.Write(Tags{"message": "b"})
+		if err := sink.Write(Tags{"message": "c"}); (err != nil) != c.err {
+			t.Errorf("%s: unexpected error %v", c.policy, err)
+		}
+		if status := sink.Status(); status["dropped"] != 1 {
+			t.Errorf("%s: unexpected status %v", c.policy, status)
+		}
+		close(release)
+		if body := <-received; !strings.Contains(body, `"message":"`+c.expected+`"`) {
+			t.Errorf("%s: expected %s, got %s", c.policy, c.expected, body)
+		}
+		sink.Close()
+		server.Close()
+	}
+}
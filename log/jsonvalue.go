@@ -0,0 +1,129 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Limits applied when marshaling struct, map and slice tag values as JSON
+const maxJSONDepth = 8
+const maxJSONElements = 100
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Returns v as a tree of maps, slices and scalars ready for json.Marshal, with
+// nesting cut at maxJSONDepth, collections cut at maxJSONElements and reference
+// cycles replaced by a marker.
+func jsonTree(v interface{}) interface{} {
+	return jsonNode(reflect.ValueOf(v), 0, map[uintptr]bool{})
+}
+
+func jsonNode(v reflect.Value, depth int, seen map[uintptr]bool) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr || v.Kind() == reflect.Map || v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			return nil
+		}
+	}
+	if v.CanInterface() {
+		if v.Type().Implements(jsonMarshalerType) {
+			return v.Interface()
+		}
+		if v.Type().Implements(errorType) {
+			return v.Interface().(error).Error()
+		}
+	}
+	if v.Kind() == reflect.Interface {
+		return jsonNode(v.Elem(), depth, seen)
+	}
+	if depth > maxJSONDepth {
+		return fmt.Sprintf("[max depth %s]", v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		ptr := v.Pointer()
+		if seen[ptr] {
+			return fmt.Sprintf("[cycle %s]", v.Type())
+		}
+		seen[ptr] = true
+		defer delete(seen, ptr)
+		if v.Kind() == reflect.Ptr {
+			return jsonNode(v.Elem(), depth, seen)
+		}
+		node := make(map[string]interface{}, v.Len())
+		for i, key := range v.MapKeys() {
+			if i == maxJSONElements {
+				node["..."] = fmt.Sprintf("%d more", v.Len()-i)
+				break
+			}
+			node[fmt.Sprint(key.Interface())] = jsonNode(v.MapIndex(key), depth+1, seen)
+		}
+		return node
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.CanInterface() {
+			if bytes, ok := v.Interface().([]byte); ok {
+				return renderValue(bytes)
+			}
+		}
+		n := v.Len()
+		if n > maxJSONElements {
+			n = maxJSONElements
+		}
+		node := make([]interface{}, n, n+1)
+		for i := 0; i < n; i++ {
+			node[i] = jsonNode(v.Index(i), depth+1, seen)
+		}
+		if v.Len() > n {
+			node = append(node, fmt.Sprintf("...%d more", v.Len()-n))
+		}
+		return node
+	case reflect.Struct:
+		node := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			name, omitEmpty := jsonFieldName(sf)
+			if name == "-" || omitEmpty && v.Field(i).IsZero() {
+				continue
+			}
+			node[name] = jsonNode(v.Field(i), depth+1, seen)
+		}
+		return node
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return fmt.Sprintf("%v", v)
+	}
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Returns the key a struct field is marshaled as, following its json tag
+func jsonFieldName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("json")
+	if tag == "" {
+		return sf.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = sf.Name
+	}
+	if tag == "-" {
+		name = "-"
+	}
+	omitEmpty := false
+	for _, opt := range parts[1:] {
+		omitEmpty = omitEmpty || opt == "omitempty"
+	}
+	return name, omitEmpty
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type jsonNodeTest struct {
+	Name     string `json:"name"`
+	Empty    string `json:"empty,omitempty"`
+	Skipped  string `json:"-"`
+	Next     *jsonNodeTest
+	private  string
+	Children []int `json:"children,omitempty"`
+}
+
+func nestedMaps(depth int) map[string]interface{} {
+	root := map[string]interface{}{}
+	node := root
+	for i := 0; i < depth; i++ {
+		child := map[string]interface{}{}
+		node["child"] = child
+		node = child
+	}
+	return root
+}
+
+func TestJSONValue(t *testing.T) {
+	cyclic := &jsonNodeTest{Name: "a"}
+	cyclic.Next = cyclic
+	shared := &jsonNodeTest{Name: "shared"}
+
+	cases := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{"scalar", 42, `42`},
+		{"error", errors.New("boom"), `"boom"`},
+		{"struct tags", jsonNodeTest{Name: "a", Skipped: "x", private: "y"}, `{"Next":null,"name":"a"}`},
+		{"cycle", cyclic, `{"Next":"[cycle *log.jsonNodeTest]","name":"a"}`},
+		{"shared pointer is not a cycle", []*jsonNodeTest{shared, shared}, `[{"Next":null,"name":"shared"},{"Next":null,"name":"shared"}]`},
+		{"long slice", make([]int, maxJSONElements+5), `,0,"...5 more"]`},
+		{"max depth", nestedMaps(maxJSONDepth + 3), `{"child":"[max depth map[string]interface {}]"}}`},
+		{"bytes", []byte("hi"), `"aGk="`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if value := string(jsonValue(c.value)); !strings.Contains(value, c.expected) {
+				t.Errorf("Expected %s, got %s", c.expected, value)
+			}
+		})
+	}
+}
+
+func TestJSONValueMapLimit(t *testing.T) {
+	m := make(map[int]int, maxJSONElements+10)
+	for i := 0; i < maxJSONElements+10; i++ {
+		m[i] = i
+	}
+	node := jsonTree(m).(map[string]interface{})
+	if len(node) != maxJSONElements+1 || node["..."] != "10 more" {
+		t.Errorf("Unexpected map of %d keys, ... = %v", len(node), node["..."])
+	}
+}
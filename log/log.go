@@ -2,9 +2,14 @@ package log
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gonzalo-mangado/logging/internal/stderr"
 	"github.com/gonzalo-mangado/logging/metrics"
 )
 
@@ -21,14 +26,20 @@ const (
 )
 
 var levelNames = map[string]int{
-	"TRACE":  TRACE,
-	"DEBUG":  DEBUG,
-	"INFO":   INFO,
-	"WARN":   WARN,
-	"ERROR":  ERROR,
-	"CRITIC": CRITIC,
-	"FATAL":  FATAL,
-	"NONE":   NONE}
+	"TRACE":    TRACE,
+	"DEBUG":    DEBUG,
+	"INFO":     INFO,
+	"METRICS":  METRICS,
+	"METRIC":   METRICS,
+	"WARN":     WARN,
+	"WARNING":  WARN,
+	"ERROR":    ERROR,
+	"ERR":      ERROR,
+	"CRITIC":   CRITIC,
+	"CRITICAL": CRITIC,
+	"FATAL":    FATAL,
+	"NONE":     NONE,
+	"OFF":      NONE}
 
 var Level = NONE
 var pushMetrics = false
@@ -37,10 +48,24 @@ func SetLevel(l int) {
 	Level = l
 }
 
+// Parses a level name (case-insensitive, aliases such as WARNING or CRITICAL
+// included) or its numeric value
+func ParseLevel(name string) (int, error) {
+	name = strings.TrimSpace(name)
+	if level, ok := levelNames[strings.ToUpper(name)]; ok {
+		return level, nil
+	}
+	if level, err := strconv.Atoi(name); err == nil {
+		return level, nil
+	}
+	return NONE, fmt.Errorf("Invalid log level: %s", name)
+}
+
+// Like ParseLevel, but panics on invalid names
 func SetLevelByName(name string) {
-	level, ok := levelNames[name]
-	if !ok {
-		panic(fmt.Sprintf("Invalid log level: %s", name))
+	level, err := ParseLevel(name)
+	if err != nil {
+		panic(err.Error())
 	}
 	SetLevel(level)
 }
@@ -48,66 +73,70 @@ func SetLevelByName(name string) {
 func SetLevelFromEnv() bool {
 	level := os.Getenv("LOG_LEVEL")
 	if level != "" {
-		SetLevelByName(strings.ToUpper(level))
+		SetLevelByName(level)
 		return true
 	}
 	return false
 }
 
 func (context logContext) Error(value interface{}, eventsAndTags ...interface{}) error {
-	err := fmt.Errorf("%v", value)
-	if Level <= ERROR {
-		context.Log("error", fmt.Sprintf("%s", err), eventsAndTags...)
+	err := asError(value)
+	if context.enabled(ERROR) {
+		context.log("error", err.Error(), err, withErrorTags(value, eventsAndTags)...)
 	}
 	return err
 }
 
 func (context logContext) Critic(value interface{}, eventsAndTags ...interface{}) error {
-	err := fmt.Errorf("%v", value)
-	if Level <= CRITIC {
-		context.Log("critic", fmt.Sprintf("%s", err), eventsAndTags...)
+	err := asError(value)
+	if context.enabled(CRITIC) {
+		context.log("critic", err.Error(), err, withErrorTags(value, eventsAndTags)...)
 	}
 	return err
 }
 
 func (context logContext) Errorf(format string, a ...interface{}) error {
 	err := fmt.Errorf(format, a...)
-	if Level <= ERROR {
-		context.Log("error", fmt.Sprintf("%s", err))
+	if context.enabled(ERROR) {
+		context.log("error", fmt.Sprintf("%s", err), err)
 	}
 	return err
 }
 
 func (context logContext) Fatalf(format string, a ...interface{}) {
-	if Level <= FATAL {
-		context.Log("fatal", format, a)
+	context.fatal(1, fmt.Errorf(format, a...))
+}
+
+func (context logContext) Warn(value interface{}, eventsAndTags ...interface{}) {
+	if !context.enabled(WARN) {
+		return
 	}
-	os.Exit(1)
+	context.Log("warn", fmt.Sprintf("%v", value), eventsAndTags...)
 }
 
 func (context logContext) Info(value interface{}, eventsAndTags ...interface{}) {
-	if Level > INFO {
+	if !context.enabled(INFO) {
 		return
 	}
 	context.Log("info", fmt.Sprintf("%v", value), eventsAndTags...)
 }
 
 func (context logContext) Debug(value interface{}, eventsAndTags ...interface{}) {
-	if Level > DEBUG {
+	if !context.enabled(DEBUG) {
 		return
 	}
 	context.Log("debug", fmt.Sprintf("%v", value), eventsAndTags...)
 }
 
 func (context logContext) Trace(value interface{}, eventsAndTags ...interface{}) {
-	if Level > TRACE {
+	if !context.enabled(TRACE) {
 		return
 	}
 	context.Log("trace", fmt.Sprintf("%v", value), eventsAndTags...)
 }
 
 func (context logContext) Metric(value interface{}, eventsAndTags ...interface{}) {
-	if Level > METRICS {
+	if !context.enabled(METRICS) {
 		return
 	}
 	context.Log("metric", fmt.Sprintf("%v", value), eventsAndTags...)
@@ -115,7 +144,15 @@ func (context logContext) Metric(value interface{}, eventsAndTags ...interface{}
 
 func (context logContext) Transaction(name string) logContext {
 	if pushMetrics {
-		return logContext{tags: context.tags, metricTags: context.metricTags, transaction: metrics.Trx(name)}
+		if context.request != nil {
+			context.transaction = metrics.TrxCtx(context.request.Context(), name)
+		} else {
+			context.transaction = metrics.Trx(name)
+		}
+		context.transaction.LabelProfile("request_id", context.requestID())
+		if traceRecords {
+			context = context.withTraceIDs()
+		}
 	}
 	return context
 }
@@ -128,6 +165,19 @@ func (context logContext) StartSegment(name string) *metrics.Segment {
 	return metrics.NullSegment()
 }
 
+// Returns the "request_id" tag of the context, or the X-Request-Id header of its request
+func (context logContext) requestID() string {
+	for i := len(context.fields) - 1; i >= 0; i-- {
+		if context.fields[i].key == "request_id" {
+			return fmt.Sprintf("%v", context.fields[i].value)
+		}
+	}
+	if context.request != nil {
+		return context.request.Header.Get("X-Request-Id")
+	}
+	return ""
+}
+
 func (context logContext) EndTransaction() {
 	if context.transaction != nil {
 		context.transaction.End()
@@ -135,36 +185,138 @@ func (context logContext) EndTransaction() {
 }
 
 func (context logContext) Log(level string, message string, eventsAndTags ...interface{}) {
-	var tags = Tags{}
+	context.log(level, message, nil, eventsAndTags...)
+}
+
+func (context logContext) log(level string, message string, err error, eventsAndTags ...interface{}) Tags {
+	if context.muted || (!context.unsampled && !sampled(level)) {
+		// Sampling and If apply to the records, not to the metrics passed along
+		context.pushUnloggedMetrics(eventsAndTags)
+		return nil
+	}
+	global := globalFields
+	fs := make(fields, 0, len(global)+len(context.fields)+len(eventsAndTags)+3)
+	fs = append(fs, global...)
+	fs = append(fs, context.fields...)
+	for _, lazy := range context.lazy {
+		fs = fs.appendTags(lazy())
+	}
+	fs = append(fs, field{"level", level}, field{"message", message})
+	if sequenceRecords {
+		seq, id := nextSequence()
+		fs = append(fs, seq, id)
+	}
+
+	var stack []runtime.Frame
+	if errorLevels[level] {
+		stack = callerStack()
+		fs = append(fs, field{"fingerprint", Fingerprint(message, stack)})
+	}
+
 	var metricTags = context.metricTags
-	var metric metrics.Metrics // TODO: merge multiple metrics
-	if len(eventsAndTags) > 0 {
-		for _, eventOrTag := range eventsAndTags {
-			if event, ok := eventOrTag.(string); ok {
-				tags = tags.merge(Tags{"event": event})
-			} else if extraTags, ok := eventOrTag.(Tags); ok {
-				tags = tags.merge(extraTags)
-			} else {
-				if m, ok := eventOrTag.(metrics.Metrics); ok {
-					metric = m
-					for _, value := range m.Values {
-						tags = tags.merge(Tags{value.Name: value.Value})
-					}
-				} else if mTags, ok := eventOrTag.(metrics.Tags); ok {
-					metricTags = metricTags.Merge(mTags)
-				} else {
-					panic(fmt.Sprintf("Argument must be of type Tags, Metrics or string: %v", eventOrTag))
+	var metric metrics.Metrics
+	var event string
+	var callTags []string
+	for _, eventOrTag := range eventsAndTags {
+		if e, ok := eventOrTag.(string); ok {
+			event = e
+			fs = append(fs, field{"event", e})
+		} else if e, ok := eventOrTag.(Event); ok {
+			event = string(e)
+			fs = append(fs, field{"event", event})
+		} else if extraTags, ok := eventOrTag.(Tags); ok {
+			fs = fs.appendGroup(context.group, extraTags)
+			if eventStrictness != EVENTS_LAX {
+				for k := range extraTags {
+					callTags = append(callTags, k)
+				}
+			}
+		} else {
+			if m, ok := eventOrTag.(metrics.Metrics); ok {
+				metric.Values = append(metric.Values, m.Values...)
+				for _, value := range m.Values {
+					fs = append(fs, field{value.Name, value.Value})
+					callTags = append(callTags, value.Name)
 				}
+			} else if mTags, ok := eventOrTag.(metrics.Tags); ok {
+				metricTags = metricTags.Merge(mTags)
+			} else {
+				fs = append(fs, field{"log_warning", fmt.Sprintf("Ignored argument of type %T (expected Tags, Metrics, Event or string): %v", eventOrTag, eventOrTag)})
 			}
 		}
 	}
+	if eventStrictness != EVENTS_LAX && event != "" {
+		context.validateEvent(event, callTags)
+	}
 
-	Log(context.tags.merge(Tags{"level": level, "message": message}).merge(tags))
-	if pushMetrics {
-		for _, m := range metric.Values {
-			if err := metrics.PushMetric(m, context.transaction, metricTags); err != nil {
-				context.Errorf("Error pushing metric: %s", err)
-			}
+	record := fs.tags()
+	if expanded, ok := expandTemplate(message, record); ok {
+		record["message"] = expanded
+		record["template"] = message
+	}
+	level, matched := matchPolicies(level, record)
+	if len(matched) > 0 && errorLevels[level] && stack == nil {
+		stack = callerStack()
+		record["fingerprint"] = Fingerprint(message, stack)
+	}
+	if context.buffer == nil || context.buffer.pass(context, level, record) {
+		if context.output != nil {
+			context.output.Write(record)
+		} else if context.encoded != nil && currentSigner() == nil && len(matched) == 0 && passesFilters(record) && context.encoded.write(context.fields, fs[len(global)+len(context.fields):], record) {
+			writeSinks(record, true)
+		} else {
+			Log(record)
+		}
+	}
+	runPolicies(matched, record, context.transaction)
+	if errorLevels[level] {
+		context.report(level, message, err, record, stack)
+	}
+	if code := policyExitCode(matched); code != 0 {
+		policyExit(code, record)
+	}
+	countVolume(level, event)
+	if errorLevels[level] {
+		if len(errorAlerts) > 0 {
+			countError()
+		}
+		countErrorSummary(level, record)
+	}
+	if pushMetrics && len(metric.Values) > 0 {
+		context.pushRecordMetrics(metric.Values, context.propagatedMetricTags(record, metricTags))
+	}
+	return record
+}
+
+// Pushes the metrics passed with a record that was not emitted, tagged as if it had been
+func (context logContext) pushUnloggedMetrics(eventsAndTags []interface{}) {
+	if !pushMetrics {
+		return
+	}
+	var values []metrics.Metric
+	metricTags := context.metricTags
+	record := Tags{}
+	for _, eventOrTag := range eventsAndTags {
+		switch arg := eventOrTag.(type) {
+		case metrics.Metrics:
+			values = append(values, arg.Values...)
+		case metrics.Tags:
+			metricTags = metricTags.Merge(arg)
+		case Tags:
+			record = record.merge(arg)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	record = globalFields.tags().merge(context.fields.tags()).merge(record)
+	context.pushRecordMetrics(values, context.propagatedMetricTags(record, metricTags))
+}
+
+func (context logContext) pushRecordMetrics(values []metrics.Metric, metricTags metrics.Tags) {
+	for _, m := range values {
+		if err := metrics.PushMetric(m, context.transaction, metricTags); err != nil {
+			context.Errorf("Error pushing metric: %s", err)
 		}
 	}
 }
@@ -172,11 +324,37 @@ func (context logContext) Log(level string, message string, eventsAndTags ...int
 type Tags map[string]interface{}
 
 func Log(attrs Tags) {
-	var line string
-	for k, v := range attrs {
-		line += fmt.Sprintf(`[%s:%+v]`, k, v)
+	attrs, keep := writeOutput(attrs)
+	writeSinks(attrs, keep)
+}
+
+// Writes the record to the output if it passes the filters, signed when
+// SignRecords was called. The signer is held until the record is written, so
+// the output follows the order of the chain, but not while the sinks are
+// written, so a slow one does not hold up logging. Returns the signed record.
+func writeOutput(attrs Tags) (Tags, bool) {
+	if s := currentSigner(); s != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		attrs = s.sign(attrs)
 	}
-	fmt.Println(line)
+	keep := passesFilters(attrs)
+	if keep {
+		buf := getBuffer()
+		formatter.Format(buf, attrs)
+		buf.WriteByte('\n')
+		output.Write(buf.Bytes())
+		putBuffer(buf)
+	}
+	return attrs, keep
+}
+
+func formatLine(attrs Tags) string {
+	buf := getBuffer()
+	formatter.Format(buf, attrs)
+	line := buf.String()
+	putBuffer(buf)
+	return line
 }
 
 func (tags Tags) merge(other Tags) Tags {
@@ -192,18 +370,54 @@ func (tags Tags) merge(other Tags) Tags {
 
 type logContext struct {
 	transaction *metrics.Transaction
-	tags        Tags
+	fields      fields
 	metricTags  metrics.Tags
+	request     *http.Request
+	output      Sink // Replaces the standard output and sinks when set
+	level       *int // Replaces the global Level when set
+	encoded     *encodedContext
+	name        string   // Dotted name given with Named
+	propagated  []string // Log tags added to the pushed metrics
+	muted       bool     // Set by If(false)
+	unsampled   bool     // Set for the records of the package itself, kept regardless of LOG_SAMPLING
+	lazy        []func() Tags
+	buffer      *requestBuffer // Set by Buffered
+	group       []string       // Groups opened with WithGroup
+}
+
+func (context logContext) enabled(level int) bool {
+	if context.muted {
+		return false
+	}
+	if context.buffer != nil && level < INFO && context.buffer.active() {
+		return true
+	}
+	return context.levelEnabled(level) || context.targeted(level)
 }
 
-var defaultContext = logContext{tags: Tags{}, transaction: nil, metricTags: metrics.Tags{}}
+// Like enabled, ignoring the request buffer
+func (context logContext) levelEnabled(level int) bool {
+	if context.level != nil {
+		return *context.level <= level
+	}
+	if context.name != "" {
+		return namedLevel(context.name) <= level
+	}
+	return Level <= level
+}
+
+var defaultContext = logContext{transaction: nil, metricTags: metrics.Tags{}}
 
 func Error(value interface{}, eventsAndTags ...interface{}) error {
 	return defaultContext.Error(value, eventsAndTags...)
 }
 
 func Errorf(format string, a ...interface{}) error {
-	return defaultContext.Errorf(format, a)
+	return defaultContext.Errorf(format, a...)
+}
+
+func Warn(value interface{}, eventsAndTags ...interface{}) {
+	defaultContext.Warn(value, eventsAndTags...)
 }
 
 func Info(value interface{}, eventsAndTags ...interface{}) {
@@ -223,7 +437,7 @@ func Critic(value interface{}, eventsAndTags ...interface{}) {
 }
 
 func Fatalf(format string, a ...interface{}) {
-	defaultContext.Fatalf(format, a)
+	defaultContext.Fatalf(format, a...)
 }
 
 func Metric(value interface{}, eventsAndTags ...interface{}) {
@@ -242,20 +456,150 @@ func WithContext(tags Tags) logContext {
 	return defaultContext.WithContext(tags)
 }
 
+func WithRequest(r *http.Request) logContext {
+	return defaultContext.WithRequest(r)
+}
+
 func (context logContext) WithContext(tags Tags) logContext {
-	return logContext{transaction: context.transaction, tags: context.tags.merge(tags), metricTags: context.metricTags}
+	if len(context.group) > 0 && len(tags) > 0 {
+		context.fields = context.fields[:len(context.fields):len(context.fields)].appendGroup(context.group, tags)
+	} else {
+		context.fields = context.fields.with(tags)
+	}
+	context.encoded = &encodedContext{}
+	return context
 }
 
 func (context logContext) WithMetricsContext(metricTags metrics.Tags) logContext {
-	return logContext{transaction: context.transaction, tags: context.tags, metricTags: context.metricTags.Merge(metricTags)}
+	context.metricTags = context.metricTags.Merge(metricTags)
+	return context
+}
+
+func WithOutput(sink Sink) logContext {
+	return defaultContext.WithOutput(sink)
+}
+
+// Returns a context whose records, of every level, are written to sink alone
+// instead of the standard output and the sinks, e.g. to the log of a test with
+// logtest.NewTestSink
+func (context logContext) WithOutput(sink Sink) logContext {
+	level := TRACE
+	context.output = sink
+	context.level = &level
+	return context
+}
+
+func WithLazyContext(tags func() Tags) logContext {
+	return defaultContext.WithLazyContext(tags)
+}
+
+// Returns a context whose records carry the tags returned by the given function,
+// which is only called when a record is emitted (on every emitted record), so
+// expensive enrichment costs nothing for filtered out levels
+func (context logContext) WithLazyContext(tags func() Tags) logContext {
+	context.lazy = append(context.lazy[:len(context.lazy):len(context.lazy)], tags)
+	return context
+}
+
+// Returns a copy of the context sharing no mutable state with it
+func (context logContext) Clone() logContext {
+	context.fields = append(fields(nil), context.fields...)
+	context.metricTags = metrics.Tags{}.Merge(context.metricTags)
+	context.lazy = append([]func() Tags(nil), context.lazy...)
+	context.propagated = append([]string(nil), context.propagated...)
+	context.group = append([]string(nil), context.group...)
+	context.encoded = &encodedContext{}
+	return context
+}
+
+// Returns a clone without the transaction and the HTTP request, keeping the tags,
+// to be handed to background goroutines that outlive the request
+func (context logContext) Detach() logContext {
+	context = context.Clone()
+	context.transaction = nil
+	context.request = nil
+	context.buffer = nil
+	return context
+}
+
+// Returns a context whose records are discarded when cond is false, for
+// conditional (per-tenant, feature-flagged, ...) logging. Fatal calls still exit.
+func (context logContext) If(cond bool) logContext {
+	if !cond {
+		context.muted = true
+	}
+	return context
+}
+
+// Attaches the HTTP request being served, reported along with errors sent to Sentry
+func (context logContext) WithRequest(r *http.Request) logContext {
+	context.request = r
+	return context
 }
 
 func PushMetrics(prefix string, enviroment string) {
+	ConfigurePushMetrics(PushMetricsConfig{Prefix: prefix, Environment: enviroment})
+}
+
+// Settings of ConfigurePushMetrics
+type PushMetricsConfig struct {
+	Prefix      string
+	Environment string // Sent as the "cluster" tag
+	DefaultTags metrics.Tags
+	// Window SIMPLE counters are aggregated over before being pushed, 0 pushes every metric
+	FlushInterval time.Duration
+	// Destination of the metrics, godog when nil
+	Backend metrics.Backend
+}
+
+// Enables pushing the metrics passed to the logging functions
+func ConfigurePushMetrics(config PushMetricsConfig) {
 	pushMetrics = true
-	metrics.UsePrefix(prefix)
-	metrics.DefaultTags(metrics.Tags{"cluster": enviroment})
+	metrics.EnablePush(true)
+	metrics.UsePrefix(config.Prefix)
+	tags := metrics.Tags{"cluster": config.Environment}
+	metrics.DefaultTags(tags.Merge(config.DefaultTags))
+	metrics.UseBackend(config.Backend)
+	metrics.AggregateCounters(config.FlushInterval)
+}
+
+// Registers a metric pushed by the package. A conflicting definition registered
+// by the application first is reported instead of panicking at import time.
+func registerMetric(name string, metricType string, options ...metrics.Option) {
+	if _, err := metrics.Register(name, metricType, options...); err != nil {
+		stderr.Logf("error", "Could not register metric %s: %s", name, err)
+	}
+}
+
+func registerCounter(name string, options ...metrics.Option) {
+	registerMetric(name, metrics.SIMPLE, options...)
 }
 
 func init() {
-	SetLevelFromEnv()
+	ConfigureFromEnv()
+	registerCounter("logging.dropped", metrics.WithTags("level", "sink"),
+		metrics.WithDescription("Records dropped by an AsyncSink whose queue was full"))
+	registerCounter("logging.security_events", metrics.WithTags("kind"),
+		metrics.WithDescription("Security events logged with SecurityEvent"))
+	registerCounter("logging.context_aborted", metrics.WithTags("operation", "reason"),
+		metrics.WithDescription("Operations aborted by a canceled context or an exceeded deadline"))
+	registerCounter("logging.panics", metrics.WithDescription("Panics recovered by Recover and Go"))
+	registerCounter("logging.dropped_webhooks", metrics.WithTags("policy"),
+		metrics.WithDescription("Webhook calls of policies dropped because their queue was full"))
+	registerCounter("logging.error_rate_alert", metrics.WithTags("window"),
+		metrics.WithDescription("Error rate alerts registered with AlertOnErrorRate that triggered"))
+	registerCounter("logging.records", metrics.WithTags("level", "event"),
+		metrics.WithDescription("Records emitted, counted by CountRecords"))
+	registerMetric("logging.connections.open", metrics.FULL, metrics.WithTags("kind"),
+		metrics.WithDescription("Connections opened with OpenConnection and not closed yet"))
+	registerCounter("logging.connection.messages", metrics.WithTags("kind", "direction"),
+		metrics.WithDescription("Messages received and sent through the connections opened with OpenConnection"))
+	registerCounter("logging.slow_ops", metrics.WithTags("operation"),
+		metrics.WithDescription("Operations run with SlowOp that exceeded their threshold"))
+	metrics.SetDryRunLogger(func(metric metrics.Tags) {
+		Debug("Metric dry run", Tags(metric))
+	})
+	metrics.SetErrorLogger(func(err error) {
+		Errorf("Error pushing metric: %s", err)
+	})
 }
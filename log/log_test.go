@@ -0,0 +1,84 @@
+package log
+
+import (
+	"io"
+	"testing"
+)
+
+func withLevel(b testing.TB, level int) {
+	previousLevel, previousOutput := Level, output
+	SetLevel(level)
+	SetOutput(io.Discard)
+	b.Cleanup(func() {
+		SetLevel(previousLevel)
+		SetOutput(previousOutput)
+	})
+}
+
+// Tags literals are built by the caller before the level is checked, so only
+// calls without them are free
+func TestDisabledLevelsDoNotAllocate(t *testing.T) {
+	withLevel(t, ERROR)
+	context := WithContext(Tags{"app": "test"})
+	calls := map[string]func(){
+		"Info":          func() { Info("message") },
+		"Debug":         func() { Debug("message") },
+		"Trace":         func() { Trace("message", "event") },
+		"Warn":          func() { Warn("message") },
+		"Metric":        func() { Metric("message") },
+		"context Info":  func() { context.Info("message") },
+		"context Debug": func() { context.Debug("message", "event") },
+	}
+	for name, call := range calls {
+		if allocs := testing.AllocsPerRun(100, call); allocs > 0 {
+			t.Errorf("%s allocated %v times", name, allocs)
+		}
+	}
+}
+
+func TestErrorWithNilPointer(t *testing.T) {
+	var nilError *pointerError
+	for name, level := range map[string]int{"enabled": TRACE, "disabled": NONE} {
+		context, sink := recordingContextAt(level)
+		err := context.Error(nilError, "payment.failed")
+		if err == nil || err.Error() != "nil" {
+			t.Errorf("%s: expected a nil error, got %v", name, err)
+		}
+		if level == TRACE && (len(sink.records) != 1 || sink.records[0]["message"] != "nil") {
+			t.Errorf("%s: expected a nil message, got %v", name, sink.records)
+		}
+	}
+}
+
+func BenchmarkDisabledInfo(b *testing.B) {
+	withLevel(b, ERROR)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Info("message")
+	}
+}
+
+func BenchmarkDisabledDebug(b *testing.B) {
+	withLevel(b, ERROR)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Debug("message", "event")
+	}
+}
+
+func BenchmarkDisabledContextInfo(b *testing.B) {
+	withLevel(b, ERROR)
+	context := WithContext(Tags{"app": "test"})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		context.Info("message", "event")
+	}
+}
+
+func BenchmarkEnabledInfo(b *testing.B) {
+	withLevel(b, INFO)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Info("message", Tags{"key": "value"})
+	}
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"strings"
+	"sync"
+)
+
+var namedLevels = map[string]int{}
+var namedLevelsLock sync.RWMutex
+
+// Returns a child context named after the dotted path of its ancestors and name,
+// tagged with it as "logger". Its level is the one set with SetNamedLevel for the
+// most specific prefix of the path, or the global Level.
+func (context logContext) Named(name string) logContext {
+	if context.name != "" {
+		name = context.name + "." + name
+	}
+	context = context.WithContext(Tags{"logger": name})
+	context.name = name
+	return context
+}
+
+func Named(name string) logContext {
+	return defaultContext.Named(name)
+}
+
+// Sets the level of the loggers named name and of their descendants
+func SetNamedLevel(name string, level int) {
+	namedLevelsLock.Lock()
+	defer namedLevelsLock.Unlock()
+	namedLevels[name] = level
+}
+
+// Removes the level set for name, which falls back to its ancestors'
+func ResetNamedLevel(name string) {
+	namedLevelsLock.Lock()
+	defer namedLevelsLock.Unlock()
+	delete(namedLevels, name)
+}
+
+func namedLevel(name string) int {
+	namedLevelsLock.RLock()
+	defer namedLevelsLock.RUnlock()
+	if len(namedLevels) == 0 {
+		return Level
+	}
+	for {
+		if level, ok := namedLevels[name]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return Level
+		}
+		name = name[:i]
+	}
+}
@@ -0,0 +1,107 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const defaultAckTimeout = 5 * time.Second
+
+// Configuration of NATSSink
+type NATSSinkConfig struct {
+	Conn *nats.Conn
+	// Subject the records are published to, "logs" by default
+	Subject string
+	// Publishes each record to Subject suffixed with its level: "logs.error", "logs.info", ...
+	LevelSubjects bool
+	// Waits for the acknowledgement of the JetStream stream capturing the subject,
+	// failing the write when the record was not stored
+	JetStream bool
+	// Time a JetStream acknowledgement is waited for, 5s by default
+	AckTimeout time.Duration
+	// JSONFormatter by default
+	Formatter Formatter
+}
+
+// Sink publishing each record to a NATS subject, for event driven log pipelines.
+// Core NATS publishes are buffered by the connection and never block; JetStream
+// ones wait for the stream, so wrap the sink in an AsyncSink to keep them off
+// the logging path.
+type NATSSink struct {
+	config NATSSinkConfig
+}
+
+func NewNATSSink(config NATSSinkConfig) *NATSSink {
+	if config.Subject == "" {
+		config.Subject = "logs"
+	}
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = defaultAckTimeout
+	}
+	if config.Formatter == nil {
+		config.Formatter = JSONFormatter{}
+	}
+	return &NATSSink{config}
+}
+
+// Returns the subject record is published to
+func (sink *NATSSink) subject(record Tags) string {
+	if level, ok := record["level"].(string); ok && sink.config.LevelSubjects && level != "" {
+		return sink.config.Subject + "." + level
+	}
+	return sink.config.Subject
+}
+
+func (sink *NATSSink) Write(record Tags) error {
+	buf := new(bytes.Buffer)
+	sink.config.Formatter.Format(buf, record)
+	data := bytes.TrimRight(buf.Bytes(), "\n")
+	subject := sink.subject(record)
+	if !sink.config.JetStream {
+		return sink.config.Conn.Publish(subject, data)
+	}
+	reply, err := sink.config.Conn.Request(subject, data, sink.config.AckTimeout)
+	if err != nil {
+		return fmt.Errorf("No JetStream acknowledgement for %s: %s", subject, err)
+	}
+	return jetStreamAckError(reply.Data)
+}
+
+// Returns the error reported by a JetStream publish acknowledgement, if any
+func jetStreamAckError(data []byte) error {
+	var ack struct {
+		Error *struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+		Stream string `json:"stream"`
+	}
+	if err := json.Unmarshal(data, &ack); err != nil {
+		return fmt.Errorf("Invalid JetStream acknowledgement: %s", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("JetStream rejected the record (%d): %s", ack.Error.Code, ack.Error.Description)
+	}
+	if ack.Stream == "" {
+		return fmt.Errorf("No JetStream stream captured the record")
+	}
+	return nil
+}
+
+// Sends the records buffered by the connection
+func (sink *NATSSink) Flush() error {
+	return sink.config.Conn.FlushTimeout(StreamFlushTimeout)
+}
+
+func (sink *NATSSink) Status() Tags {
+	conn := sink.config.Conn
+	status := Tags{"connected": conn.IsConnected(), "subject": sink.config.Subject, "jetstream": sink.config.JetStream}
+	if err := conn.LastError(); err != nil {
+		status["last_error"] = err.Error()
+	}
+	return status
+}
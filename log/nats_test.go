@@ -0,0 +1,43 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNATSSubject(t *testing.T) {
+	cases := []struct {
+		config   NATSSinkConfig
+		record   Tags
+		expected string
+	}{
+		{NATSSinkConfig{}, Tags{"level": "error"}, "logs"},
+		{NATSSinkConfig{Subject: "app"}, Tags{"level": "error"}, "app"},
+		{NATSSinkConfig{LevelSubjects: true}, Tags{"level": "error"}, "logs.error"},
+		{NATSSinkConfig{Subject: "app", LevelSubjects: true}, Tags{"level": "info"}, "app.info"},
+		{NATSSinkConfig{LevelSubjects: true}, Tags{}, "logs"},
+	}
+	for _, c := range cases {
+		if subject := NewNATSSink(c.config).subject(c.record); subject != c.expected {
+			t.Errorf("%+v, %v: expected %s, got %s", c.config, c.record, c.expected, subject)
+		}
+	}
+}
+
+func TestJetStreamAckError(t *testing.T) {
+	cases := []struct {
+		ack      string
+		expected string
+	}{
+		{`{"stream":"LOGS","seq":3}`, ""},
+		{`{"error":{"code":503,"description":"no responders"}}`, "(503): no responders"},
+		{`{}`, "No JetStream stream"},
+		{`+OK`, "Invalid JetStream acknowledgement"},
+	}
+	for _, c := range cases {
+		err := jetStreamAckError([]byte(c.ack))
+		if c.expected == "" && err != nil || c.expected != "" && (err == nil || !strings.Contains(err.Error(), c.expected)) {
+			t.Errorf("%s: expected %q, got %v", c.ack, c.expected, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+// Configuration of NewRelicLogsSink
+type NewRelicLogsSinkConfig struct {
+	LicenseKey string
+	// Sends to the EU data center instead of the US one
+	EU bool
+	// Shown as the service of the logs, linking them to the APM entity of the same name
+	Service string
+	// Added to every log, along with the hostname
+	Attributes Tags
+	// Batching, queueing and retries. URL, Encode and Headers are set by the sink.
+	HTTP HTTPSinkConfig
+}
+
+var traceRecords = false
+
+// Tags the records logged within the transactions started from now on with
+// their trace.id and span.id, so the New Relic Log API shows them in context
+// with the APM traces. Also set by LOG_TRACE_IDS.
+func TraceRecords(enabled bool) {
+	traceRecords = enabled
+}
+
+// Adds the trace IDs of the transaction to the context tags, so the encoded
+// contexts keep them with the rest of the tags
+func (context logContext) withTraceIDs() logContext {
+	if traceID, spanID := context.transaction.TraceIDs(); traceID != "" {
+		context.fields = context.fields.with(Tags{"trace.id": traceID, "span.id": spanID})
+		context.encoded = &encodedContext{}
+	}
+	return context
+}
+
+// Sink sending records to the New Relic Log API. Call TraceRecords to link the
+// records logged within a transaction to its APM trace.
+type NewRelicLogsSink struct {
+	*HTTPSink
+}
+
+func NewNewRelicLogsSink(config NewRelicLogsSinkConfig) (*NewRelicLogsSink, error) {
+	if config.LicenseKey == "" {
+		return nil, fmt.Errorf("Missing New Relic license key")
+	}
+	common := Tags{}.merge(config.Attributes)
+	if hostname, err := os.Hostname(); err == nil {
+		common["hostname"] = hostname
+	}
+	if config.Service != "" {
+		common["service.name"] = config.Service
+		common["entity.name"] = config.Service
+	}
+	httpConfig := config.HTTP
+	httpConfig.URL = "https://log-api.newrelic.com/log/v1"
+	if config.EU {
+		httpConfig.URL = "https://log-api.eu.newrelic.com/log/v1"
+	}
+	httpConfig.Headers = map[string]string{"X-License-Key": config.LicenseKey}
+	httpConfig.ContentType = "application/json"
+	httpConfig.Encode = func(records []Tags) ([]byte, error) {
+		return newRelicPayload(common, records)
+	}
+	return &NewRelicLogsSink{NewHTTPSink(httpConfig)}, nil
+}
+
+func (sink *NewRelicLogsSink) Write(record Tags) error {
+	return sink.HTTPSink.Write(stamped(record, "timestamp", clock.Now().UnixNano()/1e6))
+}
+
+// Returns the detailed Log API payload: common attributes and one entry per record
+func newRelicPayload(common Tags, records []Tags) ([]byte, error) {
+	type entry struct {
+		Timestamp  interface{}     `json:"timestamp,omitempty"`
+		Message    interface{}     `json:"message"`
+		Attributes json.RawMessage `json:"attributes"`
+	}
+	logs := make([]entry, len(records))
+	for i, record := range records {
+		attributes := Tags{}
+		for k, v := range record {
+			if k != "message" && k != "timestamp" {
+				attributes[k] = v
+			}
+		}
+		logs[i] = entry{record["timestamp"], record["message"], jsonRecord(attributes)}
+	}
+	return json.Marshal([]interface{}{map[string]interface{}{
+		"common": map[string]json.RawMessage{"attributes": jsonRecord(common)},
+		"logs":   logs,
+	}})
+}
+
+// Returns record as formatted by JSONFormatter
+func jsonRecord(record Tags) json.RawMessage {
+	buf := new(bytes.Buffer)
+	JSONFormatter{}.Format(buf, record)
+	return buf.Bytes()
+}
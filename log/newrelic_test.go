@@ -0,0 +1,120 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+func TestNewRelicPayload(t *testing.T) {
+	cases := []struct {
+		name     string
+		common   Tags
+		records  []Tags
+		expected string
+	}{
+		{"empty", Tags{}, nil, `[{"common":{"attributes":{}},"logs":[]}]`},
+		{"trace linking", Tags{"service.name": "api"},
+			[]Tags{{"message": "hello", "timestamp": int64(1700000000000), "level": "info", "trace.id": "abc", "span.id": "def"}},
+			`[{"common":{"attributes":{"service.name":"api"}},"logs":[{"timestamp":1700000000000,"message":"hello","attributes":{"level":"info","span.id":"def","trace.id":"abc"}}]}]`},
+		{"without timestamp", Tags{}, []Tags{{"message": "hello"}},
+			`[{"common":{"attributes":{}},"logs":[{"message":"hello","attributes":{}}]}]`},
+	}
+	for _, c := range cases {
+		payload, err := newRelicPayload(c.common, c.records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded, expected interface{}
+		json.Unmarshal(payload, &decoded)
+		json.Unmarshal([]byte(c.expected), &expected)
+		if !reflect.DeepEqual(decoded, expected) {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, payload)
+		}
+	}
+}
+
+func TestNewNewRelicLogsSink(t *testing.T) {
+	if _, err := NewNewRelicLogsSink(NewRelicLogsSinkConfig{}); err == nil {
+		t.Error("Expected an error without license key")
+	}
+	sink, err := NewNewRelicLogsSink(NewRelicLogsSinkConfig{LicenseKey: "key", EU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if sink.config.URL != "https://log-api.eu.newrelic.com/log/v1" || sink.config.Headers["X-License-Key"] != "key" {
+		t.Errorf("Unexpected config %+v", sink.config)
+	}
+}
+
+type tracedTracer struct{}
+
+func (tracedTracer) StartTransaction(name string) metrics.TracerTransaction {
+	return tracedTransaction{}
+}
+
+func (tracedTracer) Middleware() gin.HandlerFunc {
+	return nil
+}
+
+type tracedTransaction struct{}
+
+func (tracedTransaction) StartSegment(name string) metrics.TracerSegment { return nil }
+func (tracedTransaction) NoticeError(err error)                          {}
+func (tracedTransaction) End()                                           {}
+func (tracedTransaction) TraceIDs() (string, string)                     { return "trace", "span" }
+
+func TestRecordsCarryTransactionTraceIDs(t *testing.T) {
+	defer func(push bool) { pushMetrics = push }(pushMetrics)
+	pushMetrics = true
+	metrics.UseTracer(tracedTracer{})
+	defer metrics.UseTracer(nil)
+
+	context, sink := recordingContext()
+	context.Transaction("untraced").Info("disabled")
+	TraceRecords(true)
+	defer TraceRecords(false)
+	context.Transaction("request").Info("hello")
+	context.Info("outside")
+	if _, ok := sink.records[0]["trace.id"]; ok {
+		t.Errorf("Unexpected trace IDs before TraceRecords: %v", sink.records[0])
+	}
+	if record := sink.records[1]; record["trace.id"] != "trace" || record["span.id"] != "span" {
+		t.Errorf("Expected the trace IDs of the transaction, got %v", record)
+	}
+	if _, ok := sink.records[2]["trace.id"]; ok {
+		t.Errorf("Unexpected trace IDs without transaction: %v", sink.records[2])
+	}
+}
+
+func TestEncodedRecordsCarryTransactionTraceIDs(t *testing.T) {
+	defer func(push bool) { pushMetrics = push }(pushMetrics)
+	pushMetrics = true
+	metrics.UseTracer(tracedTracer{})
+	defer metrics.UseTracer(nil)
+	TraceRecords(true)
+	defer TraceRecords(false)
+	withLevel(t, TRACE)
+	var out bytes.Buffer
+	SetOutput(&out)
+	SetFormatter(JSONFormatter{})
+	defer SetFormatter(BracketsFormatter{})
+
+	context := WithContext(Tags{"app": "api"}).Transaction("request")
+	context.Info("first")
+	context.Info("second")
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !strings.Contains(line, "trace.id") || !strings.Contains(line, "span.id") {
+			t.Errorf("Expected the trace IDs in %s", line)
+		}
+	}
+	if !bytes.Contains(context.encoded.prefix, []byte("trace.id")) {
+		t.Errorf("Expected the trace IDs in the encoded context tags, got %s", context.encoded.prefix)
+	}
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+var output io.Writer = os.Stdout
+
+// Replaces the standard output as the destination of the records
+func SetOutput(w io.Writer) {
+	output = w
+}
+
+// Writer coalescing the written lines, flushed to the underlying writer when the
+// buffered lines or bytes reach a limit, every interval, on Close and before the
+// process exits on Fatal
+type BufferedWriter struct {
+	lock     sync.Mutex
+	w        io.Writer
+	buf      bytes.Buffer
+	lines    int
+	maxLines int
+	maxSize  int
+	stop     chan struct{}
+}
+
+// Zero maxLines, maxSize or interval disables that flush trigger
+func NewBufferedWriter(w io.Writer, maxLines int, maxSize int, interval time.Duration) *BufferedWriter {
+	writer := &BufferedWriter{w: w, maxLines: maxLines, maxSize: maxSize, stop: make(chan struct{})}
+	if interval > 0 {
+		go writer.flushEvery(interval)
+	}
+	return writer
+}
+
+func (writer *BufferedWriter) Write(p []byte) (int, error) {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	writer.buf.Write(p)
+	writer.lines += bytes.Count(p, []byte{'\n'})
+	if (writer.maxLines > 0 && writer.lines >= writer.maxLines) || (writer.maxSize > 0 && writer.buf.Len() >= writer.maxSize) {
+		if err := writer.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (writer *BufferedWriter) Flush() error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	return writer.flush()
+}
+
+// Stops the periodic flush and flushes the buffered lines
+func (writer *BufferedWriter) Close() error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	select {
+	case <-writer.stop:
+	default:
+		close(writer.stop)
+	}
+	return writer.flush()
+}
+
+// Must be called with the lock held
+func (writer *BufferedWriter) flush() error {
+	writer.lines = 0
+	if writer.buf.Len() == 0 {
+		return nil
+	}
+	_, err := writer.w.Write(writer.buf.Bytes())
+	writer.buf.Reset()
+	return err
+}
+
+func (writer *BufferedWriter) flushEvery(interval time.Duration) {
+	for {
+		select {
+		case <-writer.stop:
+			return
+		case <-clock.After(interval):
+			writer.Flush()
+		}
+	}
+}
+
+func flushOutput() {
+	if f, ok := output.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			stderr.Logf("error", "Error flushing output: %s", err)
+		}
+	}
+}
+
+// Time Close waits for the metrics and tracer backends to send pending data
+var MetricsShutdownTimeout = 5 * time.Second
+
+// Shuts down the metrics, flushes the error reporters, flushes and closes the
+// output and the sinks. To be called before the process exits.
+func Close() error {
+	var errs []error
+	if err := metrics.Shutdown(MetricsShutdownTimeout); err != nil {
+		Warn("Metrics lost at shutdown", "logging.metrics_lost", Tags{"error": err.Error()})
+		errs = append(errs, err)
+	}
+	flushReporters()
+	flushOutput()
+	FlushSinks()
+	if c, ok := output.(io.Closer); ok && output != io.Writer(os.Stdout) && output != io.Writer(os.Stderr) {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sinksLock.RLock()
+	registered := append([]Sink{}, sinks...)
+	sinksLock.RUnlock()
+	for _, sink := range registered {
+		if c, ok := sink.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Error closing log outputs: %v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Logs value at critic level along with the stack, then panics with it
+func (context logContext) Panic(value interface{}, eventsAndTags ...interface{}) {
+	context.Critic(value, append(eventsAndTags, "panic", Tags{"stack": string(debug.Stack())})...)
+	panic(value)
+}
+
+// Runs f in a new goroutine that logs and recovers its panics
+func (context logContext) Go(f func()) {
+	go func() {
+		defer context.Recover()
+		f()
+	}()
+}
+
+// Must be deferred. Recovers a panic, logging it at critic level with the stack,
+// noticing it in the transaction and pushing a "logging.panics" counter.
+func (context logContext) Recover() {
+	if r := recover(); r != nil {
+		context.logPanic(r)
+	}
+}
+
+// Must be deferred, typically in main. Recovers a panic, logs it as fatal, runs
+// the fatal hooks and exits with EXIT_SOFTWARE.
+func (context logContext) RecoverFatal() {
+	if r := recover(); r != nil {
+		context.fatal(EXIT_SOFTWARE, fmt.Errorf("panic: %v", r), "panic", Tags{"stack": string(debug.Stack())})
+	}
+}
+
+func (context logContext) logPanic(r interface{}) {
+	message := fmt.Sprintf("panic: %v", r)
+	if context.transaction != nil {
+		context.transaction.NoticeError(message)
+	}
+	context.Critic(message, "panic", Tags{"stack": string(debug.Stack())}, metrics.Counter("logging.panics"))
+}
+
+func Panic(value interface{}, eventsAndTags ...interface{}) {
+	defaultContext.Panic(value, eventsAndTags...)
+}
+
+func Go(f func()) {
+	defaultContext.Go(f)
+}
+
+// Must be deferred. Like logContext.Recover, using the logContext carried by ctx.
+func Recover(ctx context.Context) {
+	if r := recover(); r != nil {
+		contextFor(ctx).logPanic(r)
+	}
+}
+
+// Must be deferred, typically in main. See logContext.RecoverFatal.
+func RecoverFatal() {
+	if r := recover(); r != nil {
+		defaultContext.fatal(EXIT_SOFTWARE, fmt.Errorf("panic: %v", r), "panic", Tags{"stack": string(debug.Stack())})
+	}
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parses a line written by the JSON, brackets or logfmt formatters back into
+// a record. Values of brackets and logfmt lines are returned as strings.
+func ParseLine(line string) (Tags, error) {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "":
+		return nil, fmt.Errorf("Empty line")
+	case line[0] == '{':
+		record := Tags{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, fmt.Errorf("Invalid JSON record: %s", err)
+		}
+		return record, nil
+	case line[0] == '[':
+		return parseBrackets(line)
+	case strings.Contains(line, "="):
+		return parseLogfmt(line)
+	}
+	return nil, fmt.Errorf("Unknown record format: %.40q", line)
+}
+
+// [key:value][key:value]... where values may contain brackets, but not "]["
+func parseBrackets(line string) (Tags, error) {
+	record := Tags{}
+	for len(line) > 0 {
+		if line[0] != '[' {
+			return nil, fmt.Errorf("Expected '[' at %.40q", line)
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("Missing ':' in %.40q", line)
+		}
+		end := strings.Index(line[colon:], "][")
+		if end < 0 {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("Missing ']' in %.40q", line)
+			}
+			end = len(line) - 1
+		} else {
+			end += colon
+		}
+		record[line[1:colon]] = line[colon+1 : end]
+		line = line[end+1:]
+	}
+	return record, nil
+}
+
+// key=value key="quoted value"...
+func parseLogfmt(line string) (Tags, error) {
+	record := Tags{}
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			return record, nil
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("Expected key=value at %.40q", line)
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+		if strings.HasPrefix(line, "\"") {
+			end := 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("Unterminated value of %s", key)
+			}
+			value, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value of %s: %s", key, err)
+			}
+			record[key] = value
+			line = line[end+1:]
+		} else {
+			end := strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			record[key] = line[:end]
+			line = line[end:]
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package log
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	cases := []struct {
+		name     string
+		line     string
+		expected Tags
+	}{
+		{"json", `{"level":"info","count":2}`, Tags{"level": "info", "count": float64(2)}},
+		{"brackets", `[level:info][message:a [nested] value]`, Tags{"level": "info", "message": "a [nested] value"}},
+		{"brackets with colon", `[url:http://x][level:warn]`, Tags{"url": "http://x", "level": "warn"}},
+		{"logfmt", `level=info message="two words" empty=""`, Tags{"level": "info", "message": "two words", "empty": ""}},
+		{"logfmt escaped quote", `message="say \"hi\""`, Tags{"message": `say "hi"`}},
+		{"surrounding spaces", "  [level:info]  ", Tags{"level": "info"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			record, err := ParseLine(c.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(record, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, record)
+			}
+		})
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	for _, line := range []string{"", "plain text", `{"broken"`, "[level", "[level:info", `message="unterminated`, "=value"} {
+		if record, err := ParseLine(line); err == nil {
+			t.Errorf("Parsed %q as %v", line, record)
+		}
+	}
+}
+
+func TestParseLineRoundTrip(t *testing.T) {
+	record := Tags{"level": "info", "message": "hello world", "user": "ann"}
+	for _, f := range []Formatter{BracketsFormatter{}, JSONFormatter{}, LogfmtFormatter{}} {
+		var buf bytes.Buffer
+		f.Format(&buf, record)
+		parsed, err := ParseLine(buf.String())
+		if err != nil {
+			t.Fatalf("%T: %s", f, err)
+		}
+		if !reflect.DeepEqual(parsed, record) {
+			t.Errorf("%T: expected %v, got %v", f, record, parsed)
+		}
+	}
+}
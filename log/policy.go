@@ -0,0 +1,333 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Rule applying actions to the records it matches, so that "page on this
+// specific event" or "exit on this severity" logic lives in configuration
+// instead of in call sites. Every
+// condition set must hold; a policy without conditions matches every record.
+// Policies are usually loaded from a JSON file with LoadPolicies:
+//
+//	[{"name": "payment-failures", "event": "payment.failed", "tags": {"provider": "stripe"},
+//	  "escalate": "critic", "webhook": "https://alerts.example.com/hooks/payments"}]
+type Policy struct {
+	Name string `json:"name"`
+
+	Level string `json:"level,omitempty"` // Minimum level of the records, e.g. "warn"
+	Event string `json:"event,omitempty"`
+	// Tags the record must carry, values compared by their printed form
+	Tags Tags `json:"tags,omitempty"`
+
+	// Level the record is raised to: warn, error or critic. Records escalated to
+	// error or critic reach the error reporters.
+	Escalate string `json:"escalate,omitempty"`
+	// Name of a sink registered with RegisterPolicySink that also receives the
+	// record, even when filters suppressed it elsewhere
+	Sink string `json:"sink,omitempty"`
+	// URL the record is POSTed to as JSON, through a bounded queue. Calls that do
+	// not fit in the queue are dropped and counted in "logging.dropped_webhooks".
+	Webhook string `json:"webhook,omitempty"`
+	// Counter pushed tagged with the policy name, when metrics are pushed
+	Metric string `json:"metric,omitempty"`
+	// Code the process exits with once the record is emitted, after running the
+	// fatal hooks and waiting for the queued webhooks, as Fatal does
+	Exit int `json:"exit,omitempty"`
+}
+
+type policy struct {
+	Policy
+	minLevel int
+	escalate string
+}
+
+// Ranks of the record levels, since ERROR, CRITIC and FATAL share a number
+var levelRanks = map[string]int{"trace": 0, "debug": 1, "info": 2, "metric": 2, "warn": 3, "error": 4, "critic": 5, "fatal": 6}
+
+var escalationLevels = map[string]string{"warn": "warn", "warning": "warn", "error": "error", "err": "error",
+	"critic": "critic", "critical": "critic"}
+
+var policies []*policy
+var policiesLock sync.RWMutex
+
+// Number of policies, read without the lock so records are not slowed down
+// while there are none
+var policyCount int32
+
+var policySinks = map[string]Sink{}
+var policySinksLock sync.RWMutex
+
+var policyClient = &http.Client{Timeout: 5 * time.Second}
+
+// Size of the queue of webhook calls, and number of goroutines making them
+const webhookQueueSize = 256
+const webhookWorkers = 4
+
+type webhookCall struct {
+	policy *policy
+	record Tags
+}
+
+var webhookQueue = make(chan webhookCall, webhookQueueSize)
+var webhookWorkersOnce sync.Once
+var webhooksPending sync.WaitGroup
+var droppedWebhooks int64
+
+// Makes sink available to the policies under name
+func RegisterPolicySink(name string, sink Sink) {
+	policySinksLock.Lock()
+	defer policySinksLock.Unlock()
+	policySinks[name] = sink
+}
+
+// Replaces the policies in force, checking them all first. Sinks must be
+// registered with RegisterPolicySink before.
+func SetPolicies(list []Policy) error {
+	compiled, err := compilePolicies(list)
+	if err != nil {
+		return err
+	}
+	policiesLock.Lock()
+	defer policiesLock.Unlock()
+	policies = compiled
+	atomic.StoreInt32(&policyCount, int32(len(compiled)))
+	return nil
+}
+
+// Returns the error SetPolicies would return for list, without setting them
+func ValidatePolicies(list []Policy) error {
+	_, err := compilePolicies(list)
+	return err
+}
+
+func compilePolicies(list []Policy) ([]*policy, error) {
+	compiled := make([]*policy, len(list))
+	for i, p := range list {
+		c, err := compilePolicy(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid policy %d (%s): %s", i, p.Name, err)
+		}
+		compiled[i] = c
+	}
+	return compiled, nil
+}
+
+// Sets the policies defined in a JSON file holding an array of Policy. Also set
+// by the LOG_POLICY_FILE env var.
+func LoadPolicies(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read policies: %s", err)
+	}
+	var list []Policy
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("Could not parse policies in %s: %s", path, err)
+	}
+	return SetPolicies(list)
+}
+
+// Removes the policies in force
+func ClearPolicies() {
+	SetPolicies(nil)
+}
+
+// Returns the policies in force
+func Policies() []Policy {
+	policiesLock.RLock()
+	defer policiesLock.RUnlock()
+	list := make([]Policy, len(policies))
+	for i, p := range policies {
+		list[i] = p.Policy
+	}
+	return list
+}
+
+func compilePolicy(p Policy) (*policy, error) {
+	c := &policy{Policy: p, minLevel: TRACE}
+	if p.Level != "" {
+		level, err := ParseLevel(p.Level)
+		if err != nil {
+			return nil, err
+		}
+		c.minLevel = level
+	}
+	if p.Escalate != "" {
+		escalate, ok := escalationLevels[strings.ToLower(p.Escalate)]
+		if !ok {
+			return nil, fmt.Errorf("Cannot escalate to %s: must be warn, error or critic", p.Escalate)
+		}
+		c.escalate = escalate
+	}
+	if p.Sink != "" {
+		policySinksLock.RLock()
+		_, ok := policySinks[p.Sink]
+		policySinksLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("Unknown sink: %s", p.Sink)
+		}
+	}
+	if p.Exit < 0 {
+		return nil, fmt.Errorf("Invalid exit code: %d", p.Exit)
+	}
+	if p.Escalate == "" && p.Sink == "" && p.Webhook == "" && p.Metric == "" && p.Exit == 0 {
+		return nil, fmt.Errorf("No action")
+	}
+	return c, nil
+}
+
+func (p *policy) matches(record Tags) bool {
+	if p.minLevel > TRACE {
+		level, ok := levelNames[strings.ToUpper(fmt.Sprint(record["level"]))]
+		if !ok || level < p.minLevel {
+			return false
+		}
+	}
+	if p.Event != "" && fmt.Sprint(record["event"]) != p.Event {
+		return false
+	}
+	for key, value := range p.Tags {
+		if actual, ok := record[key]; !ok || fmt.Sprint(actual) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the policies matching record, which is escalated to the highest level
+// they set, along with its level after the escalation
+func matchPolicies(level string, record Tags) (string, []*policy) {
+	if atomic.LoadInt32(&policyCount) == 0 {
+		return level, nil
+	}
+	policiesLock.RLock()
+	defer policiesLock.RUnlock()
+	var matched []*policy
+	var escalation *policy
+	escalated := level
+	for _, p := range policies {
+		if !p.matches(record) {
+			continue
+		}
+		matched = append(matched, p)
+		if p.escalate != "" && levelRanks[p.escalate] > levelRanks[escalated] {
+			escalated = p.escalate
+			escalation = p
+		}
+	}
+	if escalation != nil {
+		record["level"] = escalated
+		record["escalated_from"] = level
+		record["policy"] = escalation.Name
+	}
+	return escalated, matched
+}
+
+// Runs the sink, webhook and metric actions of the matched policies, once the
+// record was emitted
+func runPolicies(matched []*policy, record Tags, trx *metrics.Transaction) {
+	for _, p := range matched {
+		if p.Sink != "" {
+			policySinksLock.RLock()
+			sink := policySinks[p.Sink]
+			policySinksLock.RUnlock()
+			if err := sink.Write(record); err != nil {
+				writeSinkError(sink, err)
+			}
+		}
+		if p.Webhook != "" {
+			queueWebhook(p, record.merge(Tags{"policy": p.Name}), trx)
+		}
+		if p.Metric != "" && pushMetrics {
+			metrics.PushMetric(metrics.Simple(p.Metric, 1).Values[0], trx, metrics.Tags{"policy": p.Name})
+		}
+	}
+}
+
+// Returns the exit code of the first matched policy that sets one, or 0
+func policyExitCode(matched []*policy) int {
+	for _, p := range matched {
+		if p.Exit != 0 {
+			return p.Exit
+		}
+	}
+	return 0
+}
+
+// Exits with code once the queued webhooks are called, or after the timeout of
+// the policy client
+func policyExit(code int, record Tags) {
+	runFatalHooks(record)
+	done := make(chan struct{})
+	go func() {
+		webhooksPending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(policyClient.Timeout):
+		stderr.Logf("error", "Exiting with webhooks pending")
+	}
+	exit(code)
+}
+
+// Queues the webhook call of p, dropping it when the queue is full. Drops are
+// reported on stderr, since logging them could match the policy again.
+func queueWebhook(p *policy, record Tags, trx *metrics.Transaction) {
+	webhookWorkersOnce.Do(func() {
+		for i := 0; i < webhookWorkers; i++ {
+			go callWebhooks()
+		}
+	})
+	webhooksPending.Add(1)
+	select {
+	case webhookQueue <- webhookCall{p, record}:
+	default:
+		webhooksPending.Done()
+		if dropped := atomic.AddInt64(&droppedWebhooks, 1); dropped == 1 || dropped%100 == 0 {
+			stderr.Logf("error", "Webhook of policy %s dropped: queue full, %d calls dropped so far", p.Name, dropped)
+		}
+		if pushMetrics {
+			metrics.PushMetric(metrics.Simple("logging.dropped_webhooks", 1).Values[0], trx, metrics.Tags{"policy": p.Name})
+		}
+	}
+}
+
+func callWebhooks() {
+	for call := range webhookQueue {
+		postWebhook(call.policy, call.record)
+		webhooksPending.Done()
+	}
+}
+
+// Reported on stderr, since logging the failure could match the policy again
+func postWebhook(p *policy, record Tags) {
+	buf := new(bytes.Buffer)
+	JSONFormatter{}.Format(buf, record)
+	req, err := http.NewRequest(http.MethodPost, p.Webhook, buf)
+	if err != nil {
+		stderr.Logf("error", "Invalid webhook of policy %s: %s", p.Name, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := policyClient.Do(req)
+	if err != nil {
+		stderr.Logf("error", "Webhook of policy %s failed: %s", p.Name, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		stderr.Logf("error", "Webhook of policy %s responded %s", p.Name, resp.Status)
+	}
+}
@@ -0,0 +1,158 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPolicyEscalation(t *testing.T) {
+	defer ClearPolicies()
+	err := SetPolicies([]Policy{
+		{Name: "payments", Event: "payment.failed", Tags: Tags{"provider": "stripe"}, Escalate: "critic"},
+		{Name: "warnings", Level: "warn", Escalate: "error"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		log       func(context logContext)
+		level     string
+		escalated bool
+		policy    string
+	}{
+		{"matching", func(c logContext) { c.Info("Failed", "payment.failed", Tags{"provider": "stripe"}) }, "critic", true, "payments"},
+		{"other tag value", func(c logContext) { c.Info("Failed", "payment.failed", Tags{"provider": "paypal"}) }, "info", false, ""},
+		{"other event", func(c logContext) { c.Info("Paid", "payment.done", Tags{"provider": "stripe"}) }, "info", false, ""},
+		{"minimum level", func(c logContext) { c.Warn("Slow") }, "error", true, "warnings"},
+		{"highest escalation", func(c logContext) { c.Warn("Failed", "payment.failed", Tags{"provider": "stripe"}) }, "critic", true, "payments"},
+		{"already higher", func(c logContext) { c.Critic("Down") }, "critic", false, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			context, sink := recordingContext()
+			c.log(context)
+			if len(sink.records) != 1 {
+				t.Fatalf("Expected a record, got %v", sink.records)
+			}
+			record := sink.records[0]
+			if record["level"] != c.level || (record["escalated_from"] != nil) != c.escalated {
+				t.Errorf("Unexpected record %v", record)
+			}
+			if c.escalated && (record["policy"] != c.policy || record["fingerprint"] == nil && c.level != "warn") {
+				t.Errorf("Unexpected escalation tags %v", record)
+			}
+		})
+	}
+}
+
+func TestSetPoliciesValidates(t *testing.T) {
+	defer ClearPolicies()
+	RegisterPolicySink("audit", &recordingSink{})
+	cases := []struct {
+		name   string
+		policy Policy
+		valid  bool
+	}{
+		{"escalation", Policy{Escalate: "critical"}, true},
+		{"registered sink", Policy{Sink: "audit"}, true},
+		{"no action", Policy{Event: "payment.failed"}, false},
+		{"invalid level", Policy{Level: "loud", Metric: "alerts"}, false},
+		{"invalid escalation", Policy{Escalate: "debug"}, false},
+		{"unknown sink", Policy{Sink: "archive"}, false},
+	}
+	for _, c := range cases {
+		if err := SetPolicies([]Policy{c.policy}); (err == nil) != c.valid {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestPolicyActions(t *testing.T) {
+	defer ClearPolicies()
+	server := newIngestServer()
+	defer server.Close()
+	extra := &recordingSink{}
+	RegisterPolicySink("pager", extra)
+	path := filepath.Join(t.TempDir(), "policies.json")
+	data, _ := json.Marshal([]Policy{{Name: "page", Event: "db.down", Sink: "pager", Webhook: server.URL}})
+	os.WriteFile(path, data, 0644)
+	if err := LoadPolicies(path); err != nil {
+		t.Fatal(err)
+	}
+
+	context, _ := recordingContext()
+	context.Error("Database unreachable", "db.down")
+	context.Error("Other failure")
+	if len(extra.records) != 1 || extra.records[0]["event"] != "db.down" {
+		t.Errorf("Expected the matching record in the extra sink, got %v", extra.records)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		server.lock.Lock()
+		lines := append([]string(nil), server.lines...)
+		server.lock.Unlock()
+		if len(lines) > 0 {
+			var posted Tags
+			if err := json.Unmarshal([]byte(lines[0]), &posted); err != nil || posted["policy"] != "page" || posted["event"] != "db.down" {
+				t.Errorf("Unexpected webhook body %s", lines[0])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("The webhook was not called")
+}
+
+func TestPolicyExit(t *testing.T) {
+	defer ClearPolicies()
+	var codes []int
+	SetExitFunc(func(code int) { codes = append(codes, code) })
+	defer SetExitFunc(nil)
+	if err := SetPolicies([]Policy{{Name: "corruption", Event: "data.corrupted", Exit: EXIT_SOFTWARE}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPolicies([]Policy{{Name: "negative", Exit: -1}}); err == nil {
+		t.Error("Expected a negative exit code to be rejected")
+	}
+
+	context, sink := recordingContext()
+	context.Warn("Checksum mismatch", "data.corrupted")
+	context.Warn("Slow disk", "disk.slow")
+	if len(codes) != 1 || codes[0] != EXIT_SOFTWARE {
+		t.Errorf("Expected a single exit with %d, got %v", EXIT_SOFTWARE, codes)
+	}
+	if len(sink.records) != 2 {
+		t.Errorf("Expected the record emitted before exiting, got %v", sink.records)
+	}
+}
+
+func TestPolicyWebhooksDropWhenQueueIsFull(t *testing.T) {
+	defer ClearPolicies()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	if err := SetPolicies([]Policy{{Name: "page", Webhook: server.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	dropped := atomic.LoadInt64(&droppedWebhooks)
+
+	context, _ := recordingContext()
+	calls := webhookQueueSize + webhookWorkers + 10
+	for i := 0; i < calls; i++ {
+		context.Error("Database unreachable")
+	}
+	if n := atomic.LoadInt64(&droppedWebhooks) - dropped; n < 10 || n > int64(calls-webhookQueueSize) {
+		t.Errorf("Expected the calls beyond the queue dropped, got %d", n)
+	}
+	close(release)
+	webhooksPending.Wait()
+}
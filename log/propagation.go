@@ -0,0 +1,43 @@
+package log
+
+import (
+	"sync"
+
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+var propagatedTags []string
+var propagatedTagsLock sync.RWMutex
+
+// Adds the given log tags (e.g. endpoint, client_id), when present in a record,
+// to the tags of the metrics pushed along with it, in every context
+func PropagateTagsToMetrics(keys ...string) {
+	propagatedTagsLock.Lock()
+	defer propagatedTagsLock.Unlock()
+	propagatedTags = append(propagatedTags, keys...)
+}
+
+// Like PropagateTagsToMetrics, only for the records of the returned context
+func (context logContext) PropagateTagsToMetrics(keys ...string) logContext {
+	context.propagated = append(context.propagated[:len(context.propagated):len(context.propagated)], keys...)
+	return context
+}
+
+// Returns metricTags with the propagated tags of record added
+func (context logContext) propagatedMetricTags(record Tags, metricTags metrics.Tags) metrics.Tags {
+	propagatedTagsLock.RLock()
+	global := propagatedTags
+	propagatedTagsLock.RUnlock()
+	if len(global) == 0 && len(context.propagated) == 0 {
+		return metricTags
+	}
+	tags := metrics.Tags{}
+	for _, keys := range [][]string{global, context.propagated} {
+		for _, k := range keys {
+			if v, ok := record[k]; ok {
+				tags[k] = v
+			}
+		}
+	}
+	return tags.Merge(metricTags)
+}
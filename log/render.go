@@ -0,0 +1,58 @@
+package log
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/format"
+)
+
+// []byte values longer than this are rendered as truncated hex instead of base64
+const maxBase64Bytes = 1024
+const truncatedHexBytes = 64
+
+// Returns the representation used by the formatters for common types: errors
+// as their message, time.Time as RFC3339, time.Duration as milliseconds, []byte
+// as base64 (or truncated hex when large) and fmt.Stringer as String(). Nil
+// pointers implementing error or fmt.Stringer are rendered as "nil". Other values
+// are returned untouched.
+func renderValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return value
+	case error:
+		if isNil(value) {
+			return "nil"
+		}
+		return value.Error()
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case time.Duration:
+		return format.Milliseconds(value)
+	case []byte:
+		if len(value) > maxBase64Bytes {
+			return fmt.Sprintf("%s...(%d bytes)", hex.EncodeToString(value[:truncatedHexBytes]), len(value))
+		}
+		return base64.StdEncoding.EncodeToString(value)
+	case fmt.Stringer:
+		if isNil(value) {
+			return "nil"
+		}
+		return value.String()
+	}
+	return v
+}
+
+// Returns whether v holds a nil pointer, map, slice, func or chan, e.g. a nil
+// *MyError stored in an error, whose methods would panic
+func isNil(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type pointerError struct {
+	msg string
+}
+
+func (err *pointerError) Error() string {
+	return err.msg
+}
+
+type pointerStringer struct {
+	name string
+}
+
+func (s *pointerStringer) String() string {
+	return s.name
+}
+
+func TestRenderValue(t *testing.T) {
+	var nilError *pointerError
+	var nilStringer *pointerStringer
+	cases := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"error", errors.New("failed"), "failed"},
+		{"pointer error", &pointerError{"failed"}, "failed"},
+		{"nil pointer error", error(nilError), "nil"},
+		{"stringer", &pointerStringer{"orders"}, "orders"},
+		{"nil pointer stringer", nilStringer, "nil"},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+		{"bytes", []byte("hi"), "aGk="},
+		{"int", 3, 3},
+	}
+	for _, c := range cases {
+		if rendered := renderValue(c.value); rendered != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, rendered)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"errors"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Receives every error, critic and fatal record, to forward it to an error
+// tracking service (Sentry, Rollbar, Bugsnag, Airbrake, ...).
+// Reporters that buffer reports may also implement Flush(timeout time.Duration),
+// which is called before the process exits on Fatal and by FlushReporters.
+type ErrorReporter interface {
+	Report(report ErrorReport)
+}
+
+type ErrorReport struct {
+	Level   string
+	Err     error
+	Tags    Tags
+	Stack   []runtime.Frame // Innermost frame first, starting at the caller of this package
+	Request *http.Request
+}
+
+type flusher interface {
+	Flush(timeout time.Duration)
+}
+
+var errorReporters []ErrorReporter
+var errorReportersLock sync.RWMutex
+var reportersFlushTimeout = 2 * time.Second
+
+var errorLevels = map[string]bool{"error": true, "critic": true, "fatal": true}
+
+const packagePrefix = "github.com/gonzalo-mangado/logging/log."
+
+// Registers a reporter invoked on every error, critic and fatal record
+func RegisterErrorReporter(reporter ErrorReporter) {
+	errorReportersLock.Lock()
+	defer errorReportersLock.Unlock()
+	errorReporters = append(errorReporters, reporter)
+}
+
+// Returns the reporters registered so far
+func registeredReporters() []ErrorReporter {
+	errorReportersLock.RLock()
+	defer errorReportersLock.RUnlock()
+	return errorReporters
+}
+
+// Waits for every reporter to send its buffered reports
+func FlushReporters(timeout time.Duration) {
+	for _, reporter := range registeredReporters() {
+		if f, ok := reporter.(flusher); ok {
+			f.Flush(timeout)
+		}
+	}
+}
+
+func flushReporters() {
+	FlushReporters(reportersFlushTimeout)
+}
+
+func (context logContext) report(level string, message string, err error, record Tags, stack []runtime.Frame) {
+	reporters := registeredReporters()
+	if len(reporters) == 0 {
+		return
+	}
+	if err == nil {
+		err = errors.New(message)
+	}
+	report := ErrorReport{Level: level, Err: err, Tags: record, Stack: stack, Request: context.request}
+	for _, reporter := range reporters {
+		reporter.Report(report)
+	}
+}
+
+// Returns the first frame of callerStack, walking no further
+func callerFrame() (runtime.Frame, bool) {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+// Returns the stack of the goroutine, skipping the frames of this package
+func callerStack() []runtime.Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	stack := make([]runtime.Frame, 0, n)
+	for {
+		frame, more := frames.Next()
+		if len(stack) > 0 || !strings.HasPrefix(frame.Function, packagePrefix) {
+			stack = append(stack, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
@@ -0,0 +1,104 @@
+package log
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of records a buffered context keeps, the oldest are dropped beyond it
+var RequestBufferSize = 1000
+
+// Records below the level of a buffered context, kept until the request ends
+type requestBuffer struct {
+	lock    sync.Mutex
+	records []Tags
+	failed  bool
+	ended   bool
+}
+
+// Returns a context that keeps the DEBUG and TRACE records below its level in
+// memory instead of dropping them. They are written if an error or critic is
+// logged, or if EndBuffer receives a status >= 500, and discarded otherwise.
+func (context logContext) Buffered() logContext {
+	context.buffer = &requestBuffer{}
+	return context
+}
+
+// Ends the buffering of a context returned by Buffered, writing the buffered
+// records if the request failed
+func (context logContext) EndBuffer(status int) {
+	if context.buffer == nil {
+		return
+	}
+	context.buffer.lock.Lock()
+	context.buffer.ended = true
+	context.buffer.lock.Unlock()
+	if status >= 500 {
+		context.buffer.flush(context)
+	}
+	context.buffer.lock.Lock()
+	context.buffer.records = nil
+	context.buffer.lock.Unlock()
+}
+
+// Middleware giving each request a buffered context, retrieved with FromContext
+// or used by the Ctx-variant functions
+func BufferRequestLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logCtx := FromContext(c.Request.Context()).Buffered()
+		c.Request = c.Request.WithContext(IntoContext(c.Request.Context(), logCtx))
+		c.Next()
+		logCtx.EndBuffer(c.Writer.Status())
+	}
+}
+
+func (buffer *requestBuffer) active() bool {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	return !buffer.ended
+}
+
+// Returns whether the record is written now. Records below the level are
+// buffered until the request ends, and dropped once it ended without failing.
+// Error records write the buffered ones first.
+func (buffer *requestBuffer) pass(context logContext, level string, record Tags) bool {
+	if errorLevels[level] {
+		buffer.flush(context)
+		return true
+	}
+	if number := levelNames[strings.ToUpper(level)]; context.levelEnabled(number) || context.targeted(number) {
+		return true
+	}
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	if buffer.ended {
+		return false
+	}
+	if buffer.failed {
+		return true
+	}
+	if len(buffer.records) >= RequestBufferSize {
+		buffer.records[0] = nil
+		buffer.records = buffer.records[1:]
+	}
+	buffer.records = append(buffer.records, record)
+	return false
+}
+
+// Writes the buffered records and lets the next ones through
+func (buffer *requestBuffer) flush(context logContext) {
+	buffer.lock.Lock()
+	records := buffer.records
+	buffer.records = nil
+	buffer.failed = true
+	buffer.lock.Unlock()
+	for _, record := range records {
+		if context.output != nil {
+			context.output.Write(record)
+		} else {
+			Log(record)
+		}
+	}
+}
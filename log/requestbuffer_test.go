@@ -0,0 +1,78 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Returns a buffered context at level INFO and the messages it wrote
+func bufferedContext() (logContext, func() string) {
+	context, sink := recordingContextAt(INFO)
+	return context.Buffered(), func() string {
+		var messages []string
+		for _, record := range sink.records {
+			messages = append(messages, fmt.Sprint(record["message"]))
+		}
+		return strings.Join(messages, ",")
+	}
+}
+
+func TestRequestBuffer(t *testing.T) {
+	cases := []struct {
+		name     string
+		log      func(context logContext)
+		expected string
+	}{
+		{"succeeded", func(c logContext) {
+			c.Debug("query")
+			c.Info("served")
+			c.EndBuffer(200)
+		}, "served"},
+		{"failed status", func(c logContext) {
+			c.Debug("query")
+			c.Trace("row")
+			c.Info("served")
+			c.EndBuffer(503)
+		}, "served,query,row"},
+		{"error logged", func(c logContext) {
+			c.Debug("query")
+			c.Error("failed")
+			c.Debug("retry")
+			c.EndBuffer(200)
+		}, "query,failed,retry"},
+		{"after end", func(c logContext) {
+			c.EndBuffer(503)
+			c.Debug("late")
+		}, ""},
+	}
+	for _, c := range cases {
+		context, written := bufferedContext()
+		c.log(context)
+		if messages := written(); messages != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, messages)
+		}
+	}
+}
+
+func TestRequestBufferDropsOldest(t *testing.T) {
+	context, written := bufferedContext()
+	for i := 0; i < RequestBufferSize+2; i++ {
+		context.Debug(i)
+	}
+	context.EndBuffer(500)
+	messages := strings.Split(written(), ",")
+	if len(messages) != RequestBufferSize || messages[0] != "2" {
+		t.Errorf("Expected %d records from 2, got %d from %s", RequestBufferSize, len(messages), messages[0])
+	}
+}
+
+// A record built while the buffer was active is dropped if the request ends
+// before it is buffered
+func TestRequestBufferEndedBeforePass(t *testing.T) {
+	context, written := bufferedContext()
+	context.EndBuffer(200)
+	if context.buffer.pass(context, "debug", Tags{"message": "late"}) || written() != "" {
+		t.Errorf("Expected the record to be dropped, got %q", written())
+	}
+}
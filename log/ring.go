@@ -0,0 +1,81 @@
+package log
+
+import (
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ringEntry struct {
+	seq    uint64
+	record Tags
+}
+
+// Sink keeping the last records in memory without locking, for post-mortem
+// inspection through StatusHandler ("?records=true") and crash dumps. Wrap it
+// with Unfiltered to capture the records the filters drop too.
+type RingSink struct {
+	slots []atomic.Value
+	next  uint64
+}
+
+func NewRingSink(size int) *RingSink {
+	if size < 1 {
+		size = 1
+	}
+	return &RingSink{slots: make([]atomic.Value, size)}
+}
+
+func (ring *RingSink) Write(record Tags) error {
+	seq := atomic.AddUint64(&ring.next, 1)
+	ring.slots[(seq-1)%uint64(len(ring.slots))].Store(ringEntry{seq, record})
+	return nil
+}
+
+// Returns the records kept, oldest first
+func (ring *RingSink) Records() []Tags {
+	last := atomic.LoadUint64(&ring.next)
+	size := uint64(len(ring.slots))
+	entries := make([]ringEntry, 0, size)
+	for i := range ring.slots {
+		entry, ok := ring.slots[i].Load().(ringEntry)
+		// Skip slots overwritten after last was read
+		if ok && entry.seq <= last && entry.seq+size > last {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	records := make([]Tags, len(entries))
+	for i, entry := range entries {
+		records[i] = entry.record
+	}
+	return records
+}
+
+func (ring *RingSink) Status() Tags {
+	return Tags{"capacity": len(ring.slots), "written": atomic.LoadUint64(&ring.next)}
+}
+
+// Handler serving the records as JSON
+func (ring *RingSink) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, ring.Records())
+	}
+}
+
+// Returns the first RingSink registered, or nil
+func registeredRing() *RingSink {
+	sinksLock.RLock()
+	defer sinksLock.RUnlock()
+	for _, sink := range sinks {
+		if u, ok := sink.(*unfilteredSink); ok {
+			sink = u.Sink
+		}
+		if ring, ok := sink.(*RingSink); ok {
+			return ring
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func ringMessages(ring *RingSink) string {
+	records := ring.Records()
+	messages := make([]interface{}, len(records))
+	for i, record := range records {
+		messages[i] = record["message"]
+	}
+	return fmt.Sprint(messages)
+}
+
+func TestRingSink(t *testing.T) {
+	cases := []struct {
+		size     int
+		writes   int
+		expected string
+	}{
+		{3, 0, "[]"},
+		{3, 2, "[0 1]"},
+		{3, 3, "[0 1 2]"},
+		{3, 7, "[4 5 6]"},
+		{0, 2, "[1]"},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%d records in %d slots", c.writes, c.size), func(t *testing.T) {
+			ring := NewRingSink(c.size)
+			for i := 0; i < c.writes; i++ {
+				ring.Write(Tags{"message": i})
+			}
+			if messages := ringMessages(ring); messages != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, messages)
+			}
+		})
+	}
+}
+
+func TestRingSinkConcurrentWrites(t *testing.T) {
+	ring := NewRingSink(10)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				ring.Write(Tags{"message": i})
+				ring.Records()
+			}
+		}()
+	}
+	wg.Wait()
+	if records := ring.Records(); len(records) != 10 {
+		t.Errorf("Expected 10 records, got %d", len(records))
+	}
+	if written := ring.Status()["written"]; written != uint64(4000) {
+		t.Errorf("Expected 4000 written, got %v", written)
+	}
+}
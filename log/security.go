@@ -0,0 +1,34 @@
+package log
+
+import (
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Kinds of security events
+const (
+	AUTH_FAILURE      = "auth_failure"
+	PERMISSION_DENIED = "permission_denied"
+	RATE_LIMITED      = "rate_limited"
+)
+
+var securityEventKinds = map[string]bool{
+	AUTH_FAILURE:      true,
+	PERMISSION_DENIED: true,
+	RATE_LIMITED:      true,
+}
+
+// Logs a security event at warn level, regardless of LOG_LEVEL, and pushes a
+// "logging.security_events" counter tagged with its kind. Kinds outside the controlled
+// vocabulary are rejected with an error.
+func (context logContext) SecurityEvent(kind string, tags Tags) error {
+	if !securityEventKinds[kind] {
+		return context.Errorf("Unknown security event kind: %s", kind)
+	}
+	context.Log("warn", "Security event: "+kind, "security_event", Tags{"security_event": kind}.merge(tags),
+		metrics.Counter("logging.security_events"), metrics.Tags{"kind": kind})
+	return nil
+}
+
+func SecurityEvent(kind string, tags Tags) error {
+	return defaultContext.SecurityEvent(kind, tags)
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+var sentryLevels = map[string]sentry.Level{
+	"error":  sentry.LevelError,
+	"critic": sentry.LevelFatal,
+	"fatal":  sentry.LevelFatal,
+}
+
+// Sends every error, critic and fatal record to Sentry
+func UseSentry(dsn string, environment string) error {
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              dsn,
+		Environment:      environment,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return fmt.Errorf("Could not create sentry client: %s", err)
+	}
+	RegisterErrorReporter(sentryReporter{})
+	return nil
+}
+
+// Waits until buffered Sentry events are sent, to be called before the process exits
+func FlushSentry(timeout time.Duration) bool {
+	return sentry.Flush(timeout)
+}
+
+type sentryReporter struct{}
+
+func (sentryReporter) Flush(timeout time.Duration) {
+	sentry.Flush(timeout)
+}
+
+func (sentryReporter) Report(report ErrorReport) {
+	event := sentry.NewEvent()
+	event.Level = sentryLevels[report.Level]
+	event.Message = report.Err.Error()
+	event.Exception = []sentry.Exception{{
+		Type:       fmt.Sprintf("%T", report.Err),
+		Value:      report.Err.Error(),
+		Stacktrace: sentryStacktrace(report),
+	}}
+	for k, v := range report.Tags {
+		if k == "message" || k == "level" {
+			continue
+		}
+		if k == "fingerprint" {
+			event.Fingerprint = []string{fmt.Sprintf("%v", v)}
+			continue
+		}
+		if k == "event" {
+			event.Tags["event"] = fmt.Sprintf("%v", v)
+			continue
+		}
+		event.Extra[k] = v
+	}
+	if report.Request != nil {
+		event.Request = sentry.NewRequest(report.Request)
+	}
+	sentry.CaptureEvent(event)
+}
+
+// Sentry expects the outermost frame first
+func sentryStacktrace(report ErrorReport) *sentry.Stacktrace {
+	frames := make([]sentry.Frame, 0, len(report.Stack))
+	for i := len(report.Stack) - 1; i >= 0; i-- {
+		frames = append(frames, sentry.NewFrame(report.Stack[i]))
+	}
+	return &sentry.Stacktrace{Frames: frames}
+}
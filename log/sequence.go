@@ -0,0 +1,30 @@
+package log
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+var sequenceRecords = false
+var recordSequence uint64
+
+// Stamps every record emitted from now on with "seq", increasing by one with
+// each record of the process, and "record_id", a random ID. Remote sinks can
+// then detect reordered deliveries and gaps, and deduplicate retried ones.
+// Records discarded by the filters also leave gaps. Also set by LOG_SEQUENCE.
+func SequenceRecords(enabled bool) {
+	sequenceRecords = enabled
+}
+
+// Returns the fields stamped on the next record
+func nextSequence() (field, field) {
+	return field{"seq", atomic.AddUint64(&recordSequence, 1)}, field{"record_id", randomID()}
+}
+
+// Returns 16 random hex digits
+func randomID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
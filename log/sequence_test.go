@@ -0,0 +1,70 @@
+package log
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSequenceRecords(t *testing.T) {
+	defer SequenceRecords(false)
+	cases := []struct {
+		enabled bool
+		stamped bool
+	}{
+		{false, false},
+		{true, true},
+	}
+	for _, c := range cases {
+		SequenceRecords(c.enabled)
+		context, sink := recordingContext()
+		context.Info("first")
+		context.Info("second")
+		first, second := sink.records[0], sink.records[1]
+		if _, ok := first["seq"]; ok != c.stamped {
+			t.Errorf("Enabled %v: unexpected record %v", c.enabled, first)
+		}
+		if !c.stamped {
+			continue
+		}
+		if second["seq"].(uint64) != first["seq"].(uint64)+1 {
+			t.Errorf("Sequence not increasing by one: %v, %v", first["seq"], second["seq"])
+		}
+		if id, ok := first["record_id"].(string); !ok || len(id) != 16 || id == second["record_id"] {
+			t.Errorf("Unexpected record IDs %v, %v", first["record_id"], second["record_id"])
+		}
+	}
+}
+
+func TestSequenceIsUniqueAcrossGoroutines(t *testing.T) {
+	defer SequenceRecords(false)
+	SequenceRecords(true)
+	context, _ := recordingContext()
+	seen := map[uint64]bool{}
+	var lock sync.Mutex
+	context.output = sinkFunc(func(record Tags) error {
+		lock.Lock()
+		defer lock.Unlock()
+		seen[record["seq"].(uint64)] = true
+		return nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				context.Info("concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 800 {
+		t.Errorf("Expected 800 distinct sequence numbers, got %d", len(seen))
+	}
+}
+
+type sinkFunc func(record Tags) error
+
+func (f sinkFunc) Write(record Tags) error {
+	return f(record)
+}
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var serializers = map[reflect.Type]reflect.Value{}
+var serializersLock sync.RWMutex
+
+// Registers a serializer for the type of its only argument. serializer must be a
+// func(T) Tags; tag values of type T are then expanded into "<key>.<tag>" fields
+// wherever they are logged, instead of being rendered as a single value.
+//
+//	log.RegisterSerializer(func(u User) log.Tags {
+//	    return log.Tags{"id": u.ID, "role": u.Role}
+//	})
+//	log.Info("Logged in", log.Tags{"user": user}) // [user.id:42][user.role:admin]
+func RegisterSerializer(serializer interface{}) {
+	fn := reflect.ValueOf(serializer)
+	fnType := fn.Type()
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 1 || fnType.NumOut() != 1 || fnType.Out(0) != reflect.TypeOf(Tags(nil)) {
+		panic(fmt.Sprintf("Serializer must be a func(T) log.Tags: %T", serializer))
+	}
+	serializersLock.Lock()
+	defer serializersLock.Unlock()
+	serializers[fnType.In(0)] = fn
+}
+
+// Returns the fields for a tag, expanded by the serializer registered for its
+// value's type, if any
+func serialize(f fields, key string, value interface{}) fields {
+	if value != nil {
+		serializersLock.RLock()
+		fn, ok := serializers[reflect.TypeOf(value)]
+		serializersLock.RUnlock()
+		if ok {
+			tags := fn.Call([]reflect.Value{reflect.ValueOf(value)})[0].Interface().(Tags)
+			for k, v := range tags {
+				f = append(f, field{key + "." + k, v})
+			}
+			return f
+		}
+	}
+	return append(f, field{key, value})
+}
@@ -0,0 +1,90 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+type recordSigner struct {
+	lock  sync.Mutex
+	key   []byte
+	chain bool
+	prev  string
+}
+
+// Holds a *recordSigner, nil until SignRecords is called
+var signer atomic.Value
+
+// Stamps every emitted record with an "hmac" tag computed with key over its
+// canonical encoding. When chain is true records also carry the HMAC of the
+// previous record in "prev_hmac", the first one an empty string, so removed or
+// reordered records are detected.
+func SignRecords(key []byte, chain bool) {
+	signer.Store(&recordSigner{key: key, chain: chain})
+}
+
+func currentSigner() *recordSigner {
+	s, _ := signer.Load().(*recordSigner)
+	return s
+}
+
+// Must be called with the lock held
+func (s *recordSigner) sign(record Tags) Tags {
+	signed := record.merge(nil)
+	if s.chain {
+		signed["prev_hmac"] = s.prev
+	}
+	mac := recordHMAC(s.key, signed)
+	signed["hmac"] = mac
+	s.prev = mac
+	return signed
+}
+
+// Checks the HMAC of every record and, for chained records, that each one
+// follows the previous and that the first one starts the chain. Records may
+// hold the parsed string values of the output.
+func VerifyRecords(key []byte, records []Tags) error {
+	return VerifyRecordsAfter(key, "", records)
+}
+
+// Like VerifyRecords, for records following the one whose HMAC is prev, e.g.
+// the first records of a rotated file
+func VerifyRecordsAfter(key []byte, prev string, records []Tags) error {
+	for i, record := range records {
+		mac, ok := record["hmac"]
+		if !ok {
+			return fmt.Errorf("Record %d is not signed", i)
+		}
+		unsigned := record.merge(nil)
+		delete(unsigned, "hmac")
+		expected := recordHMAC(key, unsigned)
+		if !hmac.Equal([]byte(fmt.Sprintf("%v", mac)), []byte(expected)) {
+			return fmt.Errorf("Record %d has an invalid hmac", i)
+		}
+		if prevMac, chained := record["prev_hmac"]; chained && fmt.Sprintf("%v", prevMac) != prev {
+			if i == 0 {
+				return fmt.Errorf("Record 0 does not start the chain: previous records are missing")
+			}
+			return fmt.Errorf("Record %d does not follow record %d", i, i-1)
+		}
+		prev = expected
+	}
+	return nil
+}
+
+// Canonical encoding: JSON object of the printed values, with sorted keys
+func recordHMAC(key []byte, record Tags) string {
+	values := make(map[string]string, len(record))
+	for k, v := range record {
+		values[k] = fmt.Sprintf("%+v", v)
+	}
+	canonical, _ := json.Marshal(values)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func signedRecords(chain bool, messages ...string) []Tags {
+	s := &recordSigner{key: []byte("key"), chain: chain}
+	records := make([]Tags, len(messages))
+	for i, message := range messages {
+		records[i] = s.sign(Tags{"message": message, "n": i})
+	}
+	return records
+}
+
+func TestVerifyRecords(t *testing.T) {
+	records := signedRecords(true, "a", "b", "c")
+	tampered := signedRecords(true, "a", "b", "c")
+	tampered[1]["message"] = "x"
+
+	cases := []struct {
+		name    string
+		key     string
+		records []Tags
+		valid   bool
+	}{
+		{"valid chain", "key", records, true},
+		{"unchained", "key", signedRecords(false, "a", "b"), true},
+		{"wrong key", "other", records, false},
+		{"tampered record", "key", tampered, false},
+		{"removed record", "key", []Tags{records[0], records[2]}, false},
+		{"removed head", "key", records[1:], false},
+		{"reordered records", "key", []Tags{records[1], records[0], records[2]}, false},
+		{"unsigned record", "key", []Tags{{"message": "a"}}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := VerifyRecords([]byte(c.key), c.records); (err == nil) != c.valid {
+				t.Errorf("Unexpected result %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRecordsParsedValues(t *testing.T) {
+	records := signedRecords(true, "a", "b")
+	for _, record := range records {
+		record["n"] = "0"
+	}
+	records[1]["n"] = "1"
+	if err := VerifyRecords([]byte("key"), records); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyRecordsAfter(t *testing.T) {
+	records := signedRecords(true, "a", "b", "c")
+	cases := []struct {
+		name  string
+		prev  string
+		valid bool
+	}{
+		{"previous hmac", records[0]["hmac"].(string), true},
+		{"chain start", "", false},
+		{"other hmac", records[1]["hmac"].(string), false},
+	}
+	for _, c := range cases {
+		if err := VerifyRecordsAfter([]byte("key"), c.prev, records[1:]); (err == nil) != c.valid {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+		}
+	}
+}
+
+// Sink blocking the first write until released
+type blockingSink struct {
+	blocked  chan struct{}
+	released chan struct{}
+}
+
+func (sink *blockingSink) Write(record Tags) error {
+	select {
+	case sink.blocked <- struct{}{}:
+		<-sink.released
+	default:
+	}
+	return nil
+}
+
+func TestSigningDoesNotWaitForSinks(t *testing.T) {
+	withLevel(t, INFO)
+	SignRecords([]byte("key"), true)
+	defer signer.Store((*recordSigner)(nil))
+	sink := &blockingSink{make(chan struct{}), make(chan struct{})}
+	AddSink(sink)
+	defer RemoveSink(sink)
+	go Log(Tags{"message": "blocked"})
+	<-sink.blocked
+	defer close(sink.released)
+	done := make(chan struct{})
+	go func() {
+		Log(Tags{"message": "next"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("A record waited for the sink blocking another one")
+	}
+}
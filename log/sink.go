@@ -0,0 +1,103 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+)
+
+// Destination that receives every emitted record along with the standard output.
+// Sinks that buffer records may also implement Flush() error, called by FlushSinks
+// and before the process exits on Fatal.
+type Sink interface {
+	Write(record Tags) error
+}
+
+type sinkFlusher interface {
+	Flush() error
+}
+
+var sinks []Sink
+var sinksLock sync.RWMutex
+
+// Registers a sink that receives every record emitted from now on
+func AddSink(sink Sink) {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+	sinks = append(sinks, sink)
+}
+
+// Unregisters a sink added with AddSink
+func RemoveSink(sink Sink) {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+	for i, s := range sinks {
+		if s == sink {
+			sinks = append(sinks[:i:i], sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Sinks not wrapped with Unfiltered only receive the records that passed the filters
+func writeSinks(record Tags, passedFilters bool) {
+	sinksLock.RLock()
+	defer sinksLock.RUnlock()
+	for _, sink := range sinks {
+		if _, unfiltered := sink.(*unfilteredSink); !passedFilters && !unfiltered {
+			continue
+		}
+		if err := sink.Write(record); err != nil {
+			writeSinkError(sink, err)
+		}
+	}
+}
+
+// Write errors of each sink, reported by Status
+type sinkErrorStats struct {
+	count     int
+	lastError string
+	lastTime  time.Time
+}
+
+var sinkErrors = map[interface{}]*sinkErrorStats{}
+var sinkErrorsLock sync.Mutex
+
+// Sinks that cannot be map keys share the stats of their type
+func sinkKey(sink Sink) interface{} {
+	if reflect.TypeOf(sink).Comparable() {
+		return sink
+	}
+	return fmt.Sprintf("%T", sink)
+}
+
+func writeSinkError(sink Sink, err error) {
+	sinkErrorsLock.Lock()
+	stats, ok := sinkErrors[sinkKey(sink)]
+	if !ok {
+		stats = &sinkErrorStats{}
+		sinkErrors[sinkKey(sink)] = stats
+	}
+	stats.count++
+	stats.lastError = err.Error()
+	stats.lastTime = clock.Now()
+	sinkErrorsLock.Unlock()
+	stderr.Logf("error", "Error writing to sink %T: %s", sink, err)
+}
+
+// Writes the records buffered by the sinks
+func FlushSinks() {
+	sinksLock.RLock()
+	defer sinksLock.RUnlock()
+	for _, sink := range sinks {
+		if f, ok := sink.(sinkFlusher); ok {
+			if err := f.Flush(); err != nil {
+				stderr.Logf("error", "Error flushing sink %T: %s", sink, err)
+			}
+		}
+	}
+}
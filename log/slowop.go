@@ -0,0 +1,53 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/format"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Runs op within a segment named after the operation. When it takes longer
+// than threshold, logs a "slow_op" warning with its duration and tags, pushes a
+// "logging.slow_ops" counter and marks the segment with a "slow" attribute:
+//
+//	err := context.SlowOp("db.query", 200*time.Millisecond, log.Tags{"table": "orders"}, func() error {
+//		return db.QueryRow(query, id).Scan(&order)
+//	})
+//
+// Returns the error of op. A panic of op ends the segment as failed and is
+// propagated.
+func (context logContext) SlowOp(operation string, threshold time.Duration, tags Tags, op func() error) (err error) {
+	seg := context.StartSegment(operation)
+	start := clock.Now()
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		elapsed := clock.Since(start)
+		if elapsed > threshold {
+			seg.AddAttribute("slow", true)
+			slowTags := Tags{"operation": operation, "duration_ms": format.Milliseconds(elapsed),
+				"threshold_ms": format.Milliseconds(threshold)}
+			if err != nil {
+				slowTags["error"] = err.Error()
+			}
+			context.Warn(fmt.Sprintf("Slow operation \"%s\"", operation), "slow_op", slowTags.merge(tags),
+				metrics.Counter("logging.slow_ops"), metrics.Tags{"operation": operation})
+		}
+		seg.EndWith(err)
+		if r != nil {
+			panic(r)
+		}
+	}()
+	return op()
+}
+
+// Like logContext.SlowOp, for the logContext carried by ctx
+func SlowOp(ctx context.Context, operation string, threshold time.Duration, tags Tags, op func() error) error {
+	return contextFor(ctx).SlowOp(operation, threshold, tags, op)
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSlowOp(t *testing.T) {
+	now := useStoppedClock(t, time.Unix(1000, 0))
+	failure := errors.New("timeout")
+	cases := []struct {
+		name     string
+		duration time.Duration
+		err      error
+		panic    interface{}
+		slow     bool
+		failure  interface{} // Error tag of the warning
+	}{
+		{"fast", 50 * time.Millisecond, nil, nil, false, nil},
+		{"at threshold", 100 * time.Millisecond, nil, nil, false, nil},
+		{"slow", 250 * time.Millisecond, nil, nil, true, nil},
+		{"slow and failed", 250 * time.Millisecond, failure, nil, true, "timeout"},
+		{"fast and panicked", 50 * time.Millisecond, nil, "boom", false, nil},
+		{"slow and panicked", 250 * time.Millisecond, nil, "boom", true, "panic: boom"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			context, sink := recordingContext()
+			var err error
+			recovered := func() (r interface{}) {
+				defer func() { r = recover() }()
+				err = context.SlowOp("db.query", 100*time.Millisecond, Tags{"table": "orders"}, func() error {
+					now.now = now.now.Add(c.duration)
+					if c.panic != nil {
+						panic(c.panic)
+					}
+					return c.err
+				})
+				return nil
+			}()
+			if recovered != c.panic {
+				t.Errorf("Expected panic %v, got %v", c.panic, recovered)
+			}
+			if err != c.err {
+				t.Errorf("Expected error %v, got %v", c.err, err)
+			}
+			warnings := sink.where("level", "warn")
+			if !c.slow {
+				if len(warnings) != 0 {
+					t.Errorf("Unexpected warnings %v", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 {
+				t.Fatalf("Expected a warning, got %v", sink.records)
+			}
+			warning := warnings[0]
+			if warning["message"] != "Slow operation \"db.query\"" || warning["event"] != "slow_op" ||
+				warning["operation"] != "db.query" || warning["table"] != "orders" ||
+				warning["duration_ms"] != 250.0 || warning["threshold_ms"] != 100.0 || warning["error"] != c.failure {
+				t.Errorf("Unexpected warning %v", warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Sinks with write errors within this window make Status report "degraded"
+const statusErrorWindow = time.Minute
+
+// Implemented by sinks that report their own state in Status, like AsyncSink
+type sinkStatus interface {
+	Status() Tags
+}
+
+// Returns the live configuration and health of the logger: level, formatter,
+// sinks with their error counts, queue depths and drops, and the metrics setup
+func Status() Tags {
+	health := "ok"
+	sinksLock.RLock()
+	sinkList := make([]Tags, 0, len(sinks))
+	sinkErrorsLock.Lock()
+	for _, sink := range sinks {
+		status := Tags{"type": fmt.Sprintf("%T", sink), "errors": 0}
+		if stats, ok := sinkErrors[sinkKey(sink)]; ok {
+			status["errors"] = stats.count
+			status["last_error"] = stats.lastError
+			status["last_error_time"] = stats.lastTime
+			if clock.Since(stats.lastTime) < statusErrorWindow {
+				health = "degraded"
+			}
+		}
+		if s, ok := sink.(sinkStatus); ok {
+			for k, v := range s.Status() {
+				status[k] = v
+			}
+		}
+		sinkList = append(sinkList, status)
+	}
+	sinkErrorsLock.Unlock()
+	sinksLock.RUnlock()
+	return Tags{
+		"status":        health,
+		"level":         levelName(Level),
+		"formatter":     fmt.Sprintf("%T", formatter),
+		"sampling":      samplingRate,
+		"push_metrics":  pushMetrics,
+		"debug_targets": DebugTargets(),
+		"sinks":         sinkList,
+		"metrics":       metrics.Status(),
+	}
+}
+
+// Handler serving Status as JSON, e.g. router.GET("/status/logging", log.StatusHandler()).
+// With "?records=true" it includes the records kept by the first RingSink registered.
+func StatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := Status()
+		if c.Query("records") == "true" {
+			if ring := registeredRing(); ring != nil {
+				status["records"] = ring.Records()
+			}
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// Returns the canonical name of a level, or its number if it has none
+func levelName(level int) string {
+	for _, name := range []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "NONE"} {
+		if levelNames[name] == level {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", level)
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	stdlog "log"
+	"path/filepath"
+	"sort"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+// Reproduces the lines of the standard library logger configured with Prefix
+// and Flags (log.LstdFlags, log.Lshortfile, ...) for teams migrating from it,
+// followed by the remaining tags in logfmt:
+// 2009/01/23 01:23:23 main.go:12: message | level=info key=value
+type StdlibFormatter struct {
+	Prefix string
+	Flags  int
+}
+
+func (f StdlibFormatter) Format(buf *bytes.Buffer, record Tags) {
+	record = flattenGroups(record)
+	if f.Flags&stdlog.Lmsgprefix == 0 {
+		buf.WriteString(f.Prefix)
+	}
+	if f.Flags&(stdlog.Ldate|stdlog.Ltime|stdlog.Lmicroseconds) != 0 {
+		now := clock.Timestamp()
+		if f.Flags&stdlog.LUTC != 0 {
+			now = now.UTC()
+		}
+		if f.Flags&stdlog.Ldate != 0 {
+			buf.WriteString(now.Format("2006/01/02 "))
+		}
+		if f.Flags&(stdlog.Ltime|stdlog.Lmicroseconds) != 0 {
+			if f.Flags&stdlog.Lmicroseconds != 0 {
+				buf.WriteString(now.Format("15:04:05.000000 "))
+			} else {
+				buf.WriteString(now.Format("15:04:05 "))
+			}
+		}
+	}
+	if f.Flags&(stdlog.Lshortfile|stdlog.Llongfile) != 0 {
+		file, line := "???", 0
+		if frame, ok := callerFrame(); ok {
+			file, line = frame.File, frame.Line
+		}
+		if f.Flags&stdlog.Lshortfile != 0 {
+			file = filepath.Base(file)
+		}
+		fmt.Fprintf(buf, "%s:%d: ", file, line)
+	}
+	if f.Flags&stdlog.Lmsgprefix != 0 {
+		buf.WriteString(f.Prefix)
+	}
+	fmt.Fprintf(buf, "%v", renderValue(record["message"]))
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		if k != "message" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+	buf.WriteString(" |")
+	for _, k := range keys {
+		buf.WriteByte(' ')
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(record[k]))
+	}
+}
@@ -0,0 +1,49 @@
+package log_test
+
+import (
+	"bytes"
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gonzalo-mangado/logging/log"
+)
+
+func TestStdlibFormatterCaller(t *testing.T) {
+	defer log.SetLevel(log.Level)
+	log.SetLevel(log.INFO)
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stdout)
+	defer log.SetFormatter(log.BracketsFormatter{})
+	cases := []struct {
+		flags  int
+		prefix string
+	}{
+		{stdlog.Lshortfile, "stdformat_test.go:"},
+		{stdlog.Lmsgprefix, "app: hello"},
+	}
+	for _, c := range cases {
+		out.Reset()
+		log.SetFormatter(log.StdlibFormatter{Prefix: "app: ", Flags: c.flags})
+		log.Info("hello")
+		if !strings.Contains(out.String(), c.prefix) {
+			t.Errorf("Flags %d: expected %s in %s", c.flags, c.prefix, out.String())
+		}
+	}
+}
+
+func BenchmarkStdlibFormatter(b *testing.B) {
+	record := log.Tags{"level": "info", "message": "hello", "order": 42}
+	for name, flags := range map[string]int{"time": stdlog.LstdFlags, "caller": stdlog.LstdFlags | stdlog.Lshortfile} {
+		b.Run(name, func(b *testing.B) {
+			formatter := log.StdlibFormatter{Flags: flags}
+			buf := new(bytes.Buffer)
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				formatter.Format(buf, record)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+const (
+	defaultStreamBufferSize = 1000
+	defaultDialTimeout      = 5 * time.Second
+	defaultMaxBackoff       = 30 * time.Second
+	minBackoff              = 100 * time.Millisecond
+)
+
+// Time Flush and Close of the stream sinks wait for the buffered records to be sent
+var StreamFlushTimeout = 5 * time.Second
+
+// Time a batch may take to be written before the connection is considered stalled
+// and dropped
+var StreamWriteTimeout = 10 * time.Second
+
+// Configuration of TCPSink
+type TCPSinkConfig struct {
+	Address string
+	// Enables TLS when set. CertFile, KeyFile and CAFile also enable it.
+	TLS *tls.Config
+	// Client certificate presented to the collector
+	CertFile string
+	KeyFile  string
+	// CAs trusted instead of the system ones
+	CAFile string
+	// Records kept while disconnected (1000 by default), the oldest are dropped
+	BufferSize int
+	// JSONFormatter by default
+	Formatter   Formatter
+	DialTimeout time.Duration
+	// Upper bound of the exponential delay between reconnection attempts
+	MaxBackoff time.Duration
+}
+
+// Sink writing newline delimited records to a TCP collector (rsyslog, vector, ...)
+// from a goroutine, reconnecting with exponential backoff
+type TCPSink struct {
+	*streamSink
+}
+
+func NewTCPSink(config TCPSinkConfig) (*TCPSink, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	timeout := config.DialTimeout
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	dial := func() (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: timeout}
+		if tlsConfig != nil {
+			return tls.DialWithDialer(dialer, "tcp", config.Address, tlsConfig)
+		}
+		return dialer.Dial("tcp", config.Address)
+	}
+	return &TCPSink{newStreamSink(dial, config.Formatter, config.BufferSize, config.MaxBackoff)}, nil
+}
+
+func (config TCPSinkConfig) tlsConfig() (*tls.Config, error) {
+	if config.TLS == nil && config.CertFile == "" && config.CAFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{}
+	if config.TLS != nil {
+		tlsConfig = config.TLS.Clone()
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if tlsConfig.ServerName == "" {
+		if host, _, err := net.SplitHostPort(config.Address); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+	return tlsConfig, nil
+}
+
+// Bounded queue of encoded lines sent over a connection by a goroutine
+type streamSink struct {
+	dial       func() (net.Conn, error)
+	formatter  Formatter
+	maxBackoff time.Duration
+	lock       sync.Mutex
+	ready      *sync.Cond // Signalled when lines are queued
+	sent       *sync.Cond // Broadcast when a batch is done
+	lines      [][]byte
+	size       int
+	sending    int
+	dropped    int
+	conn       net.Conn
+	lastError  error
+	closed     bool
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func newStreamSink(dial func() (net.Conn, error), formatter Formatter, size int, maxBackoff time.Duration) *streamSink {
+	if formatter == nil {
+		formatter = JSONFormatter{}
+	}
+	if size <= 0 {
+		size = defaultStreamBufferSize
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	sink := &streamSink{dial: dial, formatter: formatter, size: size, maxBackoff: maxBackoff, stop: make(chan struct{}), done: make(chan struct{})}
+	sink.ready = sync.NewCond(&sink.lock)
+	sink.sent = sync.NewCond(&sink.lock)
+	go sink.run()
+	return sink
+}
+
+func (sink *streamSink) Write(record Tags) error {
+	buf := new(bytes.Buffer)
+	sink.formatter.Format(buf, record)
+	buf.WriteByte('\n')
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if sink.closed {
+		return fmt.Errorf("Sink closed")
+	}
+	sink.push(buf.Bytes())
+	sink.ready.Signal()
+	return nil
+}
+
+// Queues lines, dropping the oldest beyond the buffer size. Requires the lock.
+func (sink *streamSink) push(lines ...[]byte) {
+	sink.lines = append(sink.lines, lines...)
+	if over := len(sink.lines) - sink.size; over > 0 {
+		sink.dropped += over
+		sink.lines = append([][]byte(nil), sink.lines[over:]...)
+	}
+}
+
+func (sink *streamSink) run() {
+	defer close(sink.done)
+	backoff := minBackoff
+	for {
+		sink.lock.Lock()
+		for len(sink.lines) == 0 && !sink.closed {
+			sink.ready.Wait()
+		}
+		if len(sink.lines) == 0 {
+			sink.lock.Unlock()
+			return
+		}
+		batch := sink.lines
+		sink.lines = nil
+		sink.sending = len(batch)
+		sink.lock.Unlock()
+
+		unsent, err := sink.send(batch)
+
+		sink.lock.Lock()
+		sink.sending = 0
+		sink.sent.Broadcast()
+		if err != nil {
+			sink.lastError = err
+			// Put the unsent lines back ahead of the lines queued meanwhile
+			queued := sink.lines
+			sink.lines = nil
+			sink.push(append(unsent, queued...)...)
+		}
+		sink.lock.Unlock()
+		if err == nil {
+			backoff = minBackoff
+			continue
+		}
+		select {
+		case <-sink.stop:
+			return
+		case <-clock.After(backoff):
+		}
+		if backoff *= 2; backoff > sink.maxBackoff {
+			backoff = sink.maxBackoff
+		}
+	}
+}
+
+// Returns the lines of batch that were not sent. A line cut by a failed write
+// is sent again whole, on the next connection.
+func (sink *streamSink) send(batch [][]byte) ([][]byte, error) {
+	sink.lock.Lock()
+	conn := sink.conn
+	sink.lock.Unlock()
+	if conn == nil {
+		var err error
+		if conn, err = sink.dial(); err != nil {
+			return batch, err
+		}
+		sink.lock.Lock()
+		sink.conn = conn
+		sink.lock.Unlock()
+		go sink.watch(conn)
+	}
+	conn.SetWriteDeadline(time.Now().Add(StreamWriteTimeout))
+	n, err := conn.Write(bytes.Join(batch, nil))
+	if err == nil {
+		return nil, nil
+	}
+	sink.lock.Lock()
+	conn.Close()
+	if sink.conn == conn {
+		sink.conn = nil
+	}
+	sink.lock.Unlock()
+	for len(batch) > 0 && n >= len(batch[0]) {
+		n -= len(batch[0])
+		batch = batch[1:]
+	}
+	return batch, err
+}
+
+// Reads conn until the peer closes it, then drops it so the next batch
+// reconnects, since writes to a connection closed by the peer succeed until the
+// RST arrives, losing the lines written meanwhile
+func (sink *streamSink) watch(conn net.Conn) {
+	io.Copy(io.Discard, conn)
+	sink.lock.Lock()
+	conn.Close()
+	if sink.conn == conn {
+		sink.conn = nil
+	}
+	sink.lock.Unlock()
+}
+
+// Waits up to StreamFlushTimeout for the buffered records to be sent
+func (sink *streamSink) Flush() error {
+	timedOut := false
+	flushed := make(chan struct{})
+	defer close(flushed)
+	timeout := clock.After(StreamFlushTimeout)
+	go func() {
+		select {
+		case <-timeout:
+			sink.lock.Lock()
+			timedOut = true
+			sink.sent.Broadcast()
+			sink.lock.Unlock()
+		case <-flushed:
+		}
+	}()
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	for len(sink.lines)+sink.sending > 0 {
+		if timedOut {
+			return fmt.Errorf("%d records not sent: %v", len(sink.lines)+sink.sending, sink.lastError)
+		}
+		sink.sent.Wait()
+	}
+	return nil
+}
+
+// Sends the buffered records and closes the connection
+func (sink *streamSink) Close() error {
+	err := sink.Flush()
+	sink.lock.Lock()
+	if sink.closed {
+		sink.lock.Unlock()
+		return nil
+	}
+	sink.closed = true
+	sink.lines = nil
+	sink.ready.Broadcast()
+	sink.lock.Unlock()
+	close(sink.stop)
+	select {
+	case <-sink.done:
+	case <-clock.After(StreamFlushTimeout):
+		// Unblocks a write to a stalled peer
+		err = fmt.Errorf("Timed out waiting for the connection")
+	}
+	sink.lock.Lock()
+	if sink.conn != nil {
+		sink.conn.Close()
+	}
+	sink.lock.Unlock()
+	<-sink.done
+	return err
+}
+
+func (sink *streamSink) Status() Tags {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	status := Tags{"connected": sink.conn != nil, "queued": len(sink.lines) + sink.sending, "capacity": sink.size, "dropped": sink.dropped}
+	if sink.lastError != nil {
+		status["last_error"] = sink.lastError.Error()
+	}
+	return status
+}
@@ -0,0 +1,178 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serveLines(t *testing.T, ln net.Listener, n int) <-chan string {
+	lines := make(chan string, n)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for i := 0; i < n && scanner.Scan(); i++ {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+func receive(t *testing.T, lines <-chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a line")
+		return ""
+	}
+}
+
+func TestTCPSinkReconnects(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	sink, err := NewTCPSink(TCPSinkConfig{Address: addr, MaxBackoff: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	lines := serveLines(t, ln, 1)
+	sink.Write(Tags{"message": "first"})
+	if line := receive(t, lines); !strings.Contains(line, `"message":"first"`) {
+		t.Errorf("Unexpected line %s", line)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	// Lines written before the sink notices the peer closed the connection are lost
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.Status()["connected"] == true && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("Could not listen again on", addr)
+	}
+	defer ln.Close()
+	lines = serveLines(t, ln, 2)
+	sink.Write(Tags{"message": "second"})
+	sink.Write(Tags{"message": "third"})
+	for _, expected := range []string{"second", "third"} {
+		if line := receive(t, lines); !strings.Contains(line, expected) {
+			t.Errorf("Expected %s, got %s", expected, line)
+		}
+	}
+}
+
+func TestStreamSinkDropsOldest(t *testing.T) {
+	sink := newStreamSink(func() (net.Conn, error) { return nil, net.UnknownNetworkError("test") }, nil, 2, time.Hour)
+	for _, message := range []string{"a", "b", "c"} {
+		sink.Write(Tags{"message": message})
+	}
+	status := sink.Status()
+	if status["dropped"] != 1 || status["queued"] != 2 {
+		t.Errorf("Unexpected status %v", status)
+	}
+}
+
+// Connection accepting the first accept bytes written, then failing, or
+// blocking writes until closed when stall is set
+type fakeConn struct {
+	net.Conn
+	accept  int
+	stall   bool
+	written []byte
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newFakeConn(accept int, stall bool) *fakeConn {
+	return &fakeConn{accept: accept, stall: stall, closed: make(chan struct{})}
+}
+
+func (c *fakeConn) Write(p []byte) (int, error) {
+	if c.stall {
+		<-c.closed
+		return 0, net.ErrClosed
+	}
+	if len(p) <= c.accept {
+		c.written = append(c.written, p...)
+		c.accept -= len(p)
+		return len(p), nil
+	}
+	n := c.accept
+	c.written = append(c.written, p[:n]...)
+	c.accept = 0
+	return n, errors.New("connection reset")
+}
+
+func (c *fakeConn) Read(p []byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *fakeConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func TestStreamSinkRequeuesUnsentLines(t *testing.T) {
+	batch := [][]byte{[]byte("a\n"), []byte("b\n"), []byte("c\n")}
+	cases := []struct {
+		name   string
+		accept int
+		unsent string
+	}{
+		{"all written", 6, ""},
+		{"nothing written", 0, "a\nb\nc\n"},
+		{"line boundary", 2, "b\nc\n"},
+		{"cut line", 3, "b\nc\n"},
+	}
+	for _, c := range cases {
+		conn := newFakeConn(c.accept, false)
+		sink := &streamSink{dial: func() (net.Conn, error) { return conn, nil }}
+		unsent, err := sink.send(batch)
+		if string(bytes.Join(unsent, nil)) != c.unsent || (err == nil) != (c.unsent == "") {
+			t.Errorf("%s: unexpected unsent lines %q and error %v", c.name, unsent, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestStreamSinkCloseWithStalledPeer(t *testing.T) {
+	defer func(timeout time.Duration) { StreamFlushTimeout = timeout }(StreamFlushTimeout)
+	StreamFlushTimeout = 50 * time.Millisecond
+	conn := newFakeConn(0, true)
+	sink := newStreamSink(func() (net.Conn, error) { return conn, nil }, nil, 10, time.Hour)
+	sink.Write(Tags{"message": "stuck"})
+	closed := make(chan error)
+	go func() { closed <- sink.Close() }()
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Error("Expected an error for the unsent record")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close hung on a stalled peer")
+	}
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+type fingerprintCount struct {
+	level   string
+	message string
+	count   int
+}
+
+// Error and critic records counted by fingerprint between summaries
+type errorSummary struct {
+	lock   sync.Mutex
+	top    int
+	counts map[string]*fingerprintCount
+	stop   chan struct{}
+}
+
+var summary *errorSummary
+var summaryLock sync.Mutex
+
+// Logs every interval an info record with event "logging.error_summary" holding
+// the number of error and critic records, and the top fingerprints with their
+// count and a sample message, so the shape of an error storm is visible even
+// when records are filtered or dropped. Returns the function that stops it.
+func SummarizeErrors(interval time.Duration, top int) func() {
+	s := &errorSummary{top: top, counts: map[string]*fingerprintCount{}, stop: make(chan struct{})}
+	summaryLock.Lock()
+	previous := summary
+	summary = s
+	summaryLock.Unlock()
+	if previous != nil {
+		close(previous.stop)
+	}
+	go s.run(interval)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			summaryLock.Lock()
+			if summary == s {
+				summary = nil
+			}
+			summaryLock.Unlock()
+			close(s.stop)
+		})
+	}
+}
+
+func countErrorSummary(level string, record Tags) {
+	summaryLock.Lock()
+	s := summary
+	summaryLock.Unlock()
+	if s == nil {
+		return
+	}
+	fingerprint := fmt.Sprintf("%v", record["fingerprint"])
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c, ok := s.counts[fingerprint]; ok {
+		c.count++
+	} else {
+		s.counts[fingerprint] = &fingerprintCount{level, fmt.Sprintf("%v", record["message"]), 1}
+	}
+}
+
+func (s *errorSummary) run(interval time.Duration) {
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-clock.After(interval):
+			s.emit(interval)
+		}
+	}
+}
+
+// Logs and resets the counts, if there are any
+func (s *errorSummary) emit(interval time.Duration) {
+	s.lock.Lock()
+	counts := s.counts
+	s.counts = map[string]*fingerprintCount{}
+	s.lock.Unlock()
+	if len(counts) == 0 {
+		return
+	}
+	fingerprints := make([]string, 0, len(counts))
+	total := 0
+	for fingerprint, c := range counts {
+		fingerprints = append(fingerprints, fingerprint)
+		total += c.count
+	}
+	sort.Slice(fingerprints, func(i, j int) bool {
+		return counts[fingerprints[i]].count > counts[fingerprints[j]].count
+	})
+	if len(fingerprints) > s.top {
+		fingerprints = fingerprints[:s.top]
+	}
+	top := make([]Tags, len(fingerprints))
+	for i, fingerprint := range fingerprints {
+		c := counts[fingerprint]
+		top[i] = Tags{"fingerprint": fingerprint, "count": c.count, "level": c.level, "message": c.message}
+	}
+	context := defaultContext
+	context.unsampled = true
+	context.Log("info", fmt.Sprintf("%d errors in the last %s", total, interval), "logging.error_summary",
+		Tags{"errors": total, "fingerprints": len(counts), "top": top})
+}
@@ -0,0 +1,65 @@
+package log_test
+
+import (
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/log"
+	"github.com/gonzalo-mangado/logging/logtest"
+)
+
+type lockedSink struct {
+	lock    sync.Mutex
+	records []log.Tags
+}
+
+func (sink *lockedSink) Write(record log.Tags) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	sink.records = append(sink.records, record)
+	return nil
+}
+
+func (sink *lockedSink) summaries() []log.Tags {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	var summaries []log.Tags
+	for _, record := range sink.records {
+		if record["event"] == "logging.error_summary" {
+			summaries = append(summaries, record)
+		}
+	}
+	return summaries
+}
+
+func TestErrorSummaryInterval(t *testing.T) {
+	fake := logtest.UseFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer fake.Restore()
+	defer log.SetLevel(log.Level)
+	log.SetLevel(log.INFO)
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stdout)
+	sink := &lockedSink{}
+	log.AddSink(sink)
+	defer log.RemoveSink(sink)
+
+	stop := log.SummarizeErrors(time.Minute, 5)
+	defer stop()
+	fake.WaitForTimers(1)
+	log.Error("Payment failed")
+	fake.Advance(30 * time.Second)
+	if summaries := sink.summaries(); len(summaries) != 0 {
+		t.Fatalf("Unexpected summary before the interval: %v", summaries)
+	}
+	fake.Advance(30 * time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.summaries()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if summaries := sink.summaries(); len(summaries) != 1 || summaries[0]["errors"] != 1 {
+		t.Errorf("Expected a summary of one error, got %v", summaries)
+	}
+}
@@ -0,0 +1,38 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestErrorSummaryIgnoresSampling(t *testing.T) {
+	withLevel(t, TRACE)
+	SetSampling(0)
+	defer SetSampling(1)
+	sink := &recordingSink{}
+	AddSink(sink)
+	defer RemoveSink(sink)
+	s := &errorSummary{top: 1, counts: map[string]*fingerprintCount{}}
+	summaryLock.Lock()
+	summary = s
+	summaryLock.Unlock()
+	defer func() {
+		summaryLock.Lock()
+		summary = nil
+		summaryLock.Unlock()
+	}()
+
+	Error("Payment failed")
+	Error("Payment failed")
+	Critic("Database down")
+	s.emit(time.Minute)
+
+	summaries := sink.where("event", "logging.error_summary")
+	if len(summaries) != 1 {
+		t.Fatalf("Expected a summary, got %v", sink.records)
+	}
+	top := summaries[0]["top"].([]Tags)
+	if summaries[0]["errors"] != 3 || summaries[0]["fingerprints"] != 2 || len(top) != 1 || top[0]["count"] != 2 {
+		t.Errorf("Unexpected summary %v", summaries[0])
+	}
+}
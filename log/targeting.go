@@ -0,0 +1,115 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+type debugTarget struct {
+	tags    Tags
+	expires time.Time
+}
+
+var debugTargets []*debugTarget
+var debugTargetsLock sync.RWMutex
+
+// Number of targets, read without the lock so contexts are not slowed down
+// while there are none
+var debugTargetCount int32
+
+// Emits the DEBUG records of the contexts tagged with all of tags, even when the
+// level is higher, until ttl elapses or the returned function is called:
+//
+//	log.EnableDebugFor(log.Tags{"client_id": "123"}, 10*time.Minute)
+//
+// Tag values are compared by their printed form, so 123 matches "123".
+func EnableDebugFor(tags Tags, ttl time.Duration) (disable func()) {
+	target := &debugTarget{tags: tags, expires: clock.Now().Add(ttl)}
+	debugTargetsLock.Lock()
+	debugTargets = append(debugTargets, target)
+	atomic.StoreInt32(&debugTargetCount, int32(len(debugTargets)))
+	debugTargetsLock.Unlock()
+	Info("Debug enabled for a target", "debug.targeted", Tags{"target": tags, "ttl": ttl.String()})
+	return func() {
+		removeDebugTargets(func(t *debugTarget) bool { return t == target })
+	}
+}
+
+// Removes every target added with EnableDebugFor
+func DisableDebugTargets() {
+	removeDebugTargets(func(*debugTarget) bool { return true })
+}
+
+// Returns the tags of the targets that have not expired
+func DebugTargets() []Tags {
+	removeExpiredDebugTargets()
+	debugTargetsLock.RLock()
+	defer debugTargetsLock.RUnlock()
+	targets := make([]Tags, len(debugTargets))
+	for i, target := range debugTargets {
+		targets[i] = target.tags
+	}
+	return targets
+}
+
+func removeDebugTargets(remove func(*debugTarget) bool) {
+	debugTargetsLock.Lock()
+	defer debugTargetsLock.Unlock()
+	kept := debugTargets[:0:0]
+	for _, target := range debugTargets {
+		if !remove(target) {
+			kept = append(kept, target)
+		}
+	}
+	debugTargets = kept
+	atomic.StoreInt32(&debugTargetCount, int32(len(kept)))
+}
+
+func removeExpiredDebugTargets() {
+	now := clock.Now()
+	removeDebugTargets(func(t *debugTarget) bool { return !now.Before(t.expires) })
+}
+
+// Reports whether level is DEBUG or above and the context matches a target
+func (context logContext) targeted(level int) bool {
+	if level < DEBUG || atomic.LoadInt32(&debugTargetCount) == 0 {
+		return false
+	}
+	now := clock.Now()
+	expired := false
+	matched := false
+	debugTargetsLock.RLock()
+	for _, target := range debugTargets {
+		if !now.Before(target.expires) {
+			expired = true
+		} else if !matched && context.fields.match(target.tags) {
+			matched = true
+		}
+	}
+	debugTargetsLock.RUnlock()
+	if expired {
+		removeExpiredDebugTargets()
+	}
+	return matched
+}
+
+// Reports whether fs holds every tag of tags, the last field of each key winning
+func (fs fields) match(tags Tags) bool {
+	for key, value := range tags {
+		found := false
+		for i := len(fs) - 1; i >= 0; i-- {
+			if fs[i].key == key {
+				found = fmt.Sprint(fs[i].value) == fmt.Sprint(value)
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
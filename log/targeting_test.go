@@ -0,0 +1,55 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnableDebugFor(t *testing.T) {
+	withLevel(t, NONE)
+	cases := []struct {
+		name    string
+		target  Tags
+		context Tags
+		emitted bool
+	}{
+		{"matching", Tags{"client_id": "123"}, Tags{"client_id": "123", "region": "eu"}, true},
+		{"printed form", Tags{"client_id": 123}, Tags{"client_id": "123"}, true},
+		{"other value", Tags{"client_id": "123"}, Tags{"client_id": "456"}, false},
+		{"missing tag", Tags{"client_id": "123", "region": "us"}, Tags{"client_id": "123", "region": "eu"}, false},
+		{"untagged", Tags{"client_id": "123"}, Tags{}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			context, sink := recordingContextAt(INFO)
+			disable := EnableDebugFor(c.target, time.Minute)
+			defer disable()
+			context.WithContext(c.context).Debug("debugging")
+			context.WithContext(c.context).Trace("tracing")
+			if emitted := len(sink.records) > 0; emitted != c.emitted {
+				t.Errorf("Expected emitted %v, got %v", c.emitted, sink.records)
+			}
+			if len(sink.records) > 1 {
+				t.Errorf("Unexpected TRACE record %v", sink.records[1])
+			}
+		})
+	}
+}
+
+func TestDebugTargetsExpire(t *testing.T) {
+	withLevel(t, NONE)
+	now := useStoppedClock(t, time.Unix(1000, 0))
+	context, sink := recordingContextAt(INFO)
+	context = context.WithContext(Tags{"client_id": "123"})
+
+	EnableDebugFor(Tags{"client_id": "123"}, time.Minute)
+	context.Debug("targeted")
+	now.now = now.now.Add(time.Minute)
+	context.Debug("expired")
+	if len(sink.records) != 1 || sink.records[0]["message"] != "targeted" {
+		t.Errorf("Unexpected records %v", sink.records)
+	}
+	if targets := DebugTargets(); len(targets) != 0 {
+		t.Errorf("Unexpected targets %v", targets)
+	}
+}
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Replaces the {name} placeholders of message with the values of the matching
+// tags. Unknown placeholders are left untouched.
+func expandTemplate(message string, tags Tags) (string, bool) {
+	if strings.IndexByte(message, '{') < 0 {
+		return message, false
+	}
+	var out strings.Builder
+	expanded := false
+	for {
+		start := strings.IndexByte(message, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(message[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		out.WriteString(message[:start])
+		if value, ok := tags[message[start+1:end]]; ok {
+			fmt.Fprintf(&out, "%v", value)
+			expanded = true
+		} else {
+			out.WriteString(message[start : end+1])
+		}
+		message = message[end+1:]
+	}
+	out.WriteString(message)
+	return out.String(), expanded
+}
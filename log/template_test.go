@@ -0,0 +1,30 @@
+package log
+
+import "testing"
+
+func TestExpandTemplate(t *testing.T) {
+	tags := Tags{"user": "ann", "count": 3, "empty": ""}
+	cases := []struct {
+		message  string
+		expected string
+		expanded bool
+	}{
+		{"no placeholders", "no placeholders", false},
+		{"hello {user}", "hello ann", true},
+		{"{user} has {count} items", "ann has 3 items", true},
+		{"{missing} stays", "{missing} stays", false},
+		{"{user} and {missing}", "ann and {missing}", true},
+		{"empty [{empty}]", "empty []", true},
+		{"unclosed {user", "unclosed {user", false},
+		{"json {\"a\":1}", "json {\"a\":1}", false},
+		{"{}", "{}", false},
+	}
+	for _, c := range cases {
+		t.Run(c.message, func(t *testing.T) {
+			message, expanded := expandTemplate(c.message, tags)
+			if message != c.expected || expanded != c.expanded {
+				t.Errorf("Expected %q %v, got %q %v", c.expected, c.expanded, message, expanded)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"net"
+	"time"
+)
+
+// Configuration of UnixSink and UnixgramSink
+type UnixSinkConfig struct {
+	// Path of the socket the collector listens on
+	Path string
+	// JSONFormatter by default
+	Formatter Formatter
+	// Records kept by UnixSink while disconnected (1000 by default), the oldest are dropped
+	BufferSize int
+	// Upper bound of the exponential delay between UnixSink reconnection attempts
+	MaxBackoff time.Duration
+	// Records encoded larger than this (1472 bytes by default) are truncated or
+	// split by UnixgramSink
+	MaxDatagramSize int
+	// Makes UnixgramSink send oversized records as several datagrams instead of
+	// truncating them, framed as with UDPSinkConfig.Split
+	Split bool
+}
+
+// Sink writing newline delimited records to a sidecar collector over a Unix
+// stream socket, like TCPSink does over the network
+type UnixSink struct {
+	*streamSink
+}
+
+func NewUnixSink(config UnixSinkConfig) *UnixSink {
+	dial := func() (net.Conn, error) {
+		return net.DialTimeout("unix", config.Path, defaultDialTimeout)
+	}
+	return &UnixSink{newStreamSink(dial, config.Formatter, config.BufferSize, config.MaxBackoff)}
+}
+
+// Sink sending each record as a datagram to a sidecar collector over a Unix
+// datagram socket. Unlike UDPSink, records are not lost silently: when the
+// collector does not keep up, Write fails after waiting up to 100ms.
+type UnixgramSink struct {
+	*datagramSink
+}
+
+func NewUnixgramSink(config UnixSinkConfig) *UnixgramSink {
+	dial := func() (net.Conn, error) {
+		return net.Dial("unixgram", config.Path)
+	}
+	return &UnixgramSink{newDatagramSink(dial, config.Formatter, config.MaxDatagramSize, config.Split)}
+}
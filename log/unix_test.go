@@ -0,0 +1,57 @@
+package log
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnixSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.sock")
+	// Created before the collector listens, as when the sidecar starts late
+	sink := NewUnixSink(UnixSinkConfig{Path: path, MaxBackoff: 20 * time.Millisecond})
+	defer sink.Close()
+	sink.Write(Tags{"message": "early"})
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := serveLines(t, ln, 1)
+	if line := receive(t, lines); !strings.Contains(line, `"message":"early"`) {
+		t.Errorf("Unexpected line %s", line)
+	}
+}
+
+func TestUnixgramSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.sock")
+	sink := NewUnixgramSink(UnixSinkConfig{Path: path})
+	defer sink.Close()
+	if err := sink.Write(Tags{"message": "lost"}); err == nil {
+		t.Error("Expected an error without collector")
+	}
+
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := sink.Write(Tags{"message": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, defaultMaxDatagramSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received := string(buf[:n]); received != `{"message":"hello"}` {
+		t.Errorf("Unexpected datagram %s", received)
+	}
+	if status := sink.Status(); status["dropped"] != 1 {
+		t.Errorf("Unexpected status %v", status)
+	}
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Distinct events counted by CountRecords, the others are counted as "other"
+const maxVolumeEvents = 200
+
+type volumeKey struct {
+	level string
+	event string
+}
+
+// Records counted by level and event between pushes
+type volumeCollector struct {
+	counts sync.Map // volumeKey to *int64
+	events int32
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// Holds the running *volumeCollector, read on every record without locking
+var volume atomic.Value
+
+// Pushes every interval a "logging.records" metric per level and event with the
+// number of records emitted, so the events dominating the log volume show up
+// in the metrics backend. Records without event are counted as "none". Requires
+// ConfigurePushMetrics. A running collector is replaced, once its counts are
+// pushed. Returns the function that stops it, pushing the pending counts.
+func CountRecords(interval time.Duration) func() {
+	collector := &volumeCollector{stop: make(chan struct{})}
+	previous, _ := volume.Load().(*volumeCollector)
+	volume.Store(collector)
+	if previous != nil {
+		previous.close()
+	}
+	go collector.run(interval)
+	return func() {
+		if current, _ := volume.Load().(*volumeCollector); current == collector {
+			volume.Store((*volumeCollector)(nil))
+		}
+		collector.close()
+	}
+}
+
+func countVolume(level string, event string) {
+	collector, _ := volume.Load().(*volumeCollector)
+	if collector == nil {
+		return
+	}
+	if event == "" {
+		event = "none"
+	}
+	key := volumeKey{level, event}
+	count, ok := collector.counts.Load(key)
+	if !ok {
+		if atomic.LoadInt32(&collector.events) >= maxVolumeEvents {
+			key.event = "other"
+		} else {
+			atomic.AddInt32(&collector.events, 1)
+		}
+		count, _ = collector.counts.LoadOrStore(key, new(int64))
+	}
+	atomic.AddInt64(count.(*int64), 1)
+}
+
+func (collector *volumeCollector) run(interval time.Duration) {
+	for {
+		select {
+		case <-collector.stop:
+			return
+		case <-clock.After(interval):
+			collector.push()
+		}
+	}
+}
+
+// Stops the collector and pushes its pending counts
+func (collector *volumeCollector) close() {
+	collector.once.Do(func() {
+		close(collector.stop)
+		collector.push()
+	})
+}
+
+// Pushes and resets the counts. Failures are reported on stderr, since logging
+// them would be counted.
+func (collector *volumeCollector) push() {
+	var failed int
+	var lastErr error
+	collector.counts.Range(func(k, v interface{}) bool {
+		key := k.(volumeKey)
+		if n := atomic.SwapInt64(v.(*int64), 0); n > 0 && pushMetrics {
+			if err := metrics.PushMetric(metrics.Simple("logging.records", float64(n)).Values[0], nil, metrics.Tags{"level": key.level, "event": key.event}); err != nil {
+				failed++
+				lastErr = err
+			}
+		}
+		return true
+	})
+	if failed > 0 {
+		stderr.Logf("error", "Could not push %d logging.records metrics: %s", failed, lastErr)
+	}
+}
@@ -0,0 +1,75 @@
+package log
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Returns the counts of the running collector
+func volumeCounts() map[volumeKey]int64 {
+	counts := map[volumeKey]int64{}
+	collector, _ := volume.Load().(*volumeCollector)
+	collector.counts.Range(func(k, v interface{}) bool {
+		counts[k.(volumeKey)] = atomic.LoadInt64(v.(*int64))
+		return true
+	})
+	return counts
+}
+
+func TestCountRecords(t *testing.T) {
+	stop := CountRecords(time.Hour)
+	defer stop()
+	context, _ := recordingContext()
+	context.Info("one", "order.created")
+	context.Info("two", "order.created")
+	context.Warn("three", "order.created")
+	context.Info("four")
+	cases := []struct {
+		key      volumeKey
+		expected int64
+	}{
+		{volumeKey{"info", "order.created"}, 2},
+		{volumeKey{"warn", "order.created"}, 1},
+		{volumeKey{"info", "none"}, 1},
+		{volumeKey{"debug", "order.created"}, 0},
+	}
+	counts := volumeCounts()
+	for _, c := range cases {
+		if counts[c.key] != c.expected {
+			t.Errorf("%v: expected %d, got %d", c.key, c.expected, counts[c.key])
+		}
+	}
+}
+
+func TestCountRecordsBoundsEvents(t *testing.T) {
+	stop := CountRecords(time.Hour)
+	defer stop()
+	context, _ := recordingContext()
+	for i := 0; i < maxVolumeEvents+10; i++ {
+		context.Info("event", fmt.Sprintf("event.%d", i))
+	}
+	counts := volumeCounts()
+	if len(counts) != maxVolumeEvents+1 || counts[volumeKey{"info", "other"}] != 10 {
+		t.Errorf("Expected %d keys with 10 others, got %d keys and %d others", maxVolumeEvents+1, len(counts), counts[volumeKey{"info", "other"}])
+	}
+}
+
+func TestCountRecordsReplacementPushesPendingCounts(t *testing.T) {
+	backend := usePushedMetrics(t)
+	stopFirst := CountRecords(time.Hour)
+	context, _ := recordingContext()
+	context.Info("one", "order.created")
+	stop := CountRecords(time.Hour)
+	defer stop()
+	stopFirst() // Stopping a replaced collector leaves the running one alone
+	expected := "logging.records 1 event:order.created,level:info"
+	if pushed := backend.recorded(); len(pushed) != 1 || pushed[0] != expected {
+		t.Errorf("Expected %q pushed, got %v", expected, pushed)
+	}
+	context.Info("two", "order.created")
+	if counts := volumeCounts(); counts[volumeKey{"info", "order.created"}] != 1 {
+		t.Errorf("Expected the record counted by the running collector, got %v", counts)
+	}
+}
@@ -0,0 +1,245 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lines longer than this are split into several records
+var MaxWriterLineSize = 64 * 1024
+
+// Time without output after which LineWriter logs the lines it holds
+var WriterFlushDelay = 100 * time.Millisecond
+
+// io.Writer that emits every line written as a record. Stack traces and JSON
+// documents spanning several lines are emitted as a single record, so the last
+// line of a frame is only logged when the next one starts, after
+// WriterFlushDelay without output, or on Flush. A trailing line without newline
+// is kept until the next Write or Flush.
+type LineWriter struct {
+	lock     sync.Mutex
+	context  logContext
+	level    int
+	name     string // Of level, as written by Info, Warn, ...
+	buf      []byte
+	frame    frame
+	lastLine time.Time
+	delay    time.Duration // WriterFlushDelay when the last line came
+	idle     *time.Timer   // Logs the frame once no line came for delay
+}
+
+// Returns a writer for exec.Cmd Stdout and Stderr that logs each line of the
+// child's output at level, tagged with tags and the trace IDs carried by ctx:
+//
+//	cmd.Stderr = log.CommandWriter(ctx, log.WARN, log.Tags{"command": "git", "stream": "stderr"})
+//
+// Flush it once cmd.Wait returns to log the last line if it had no newline.
+func CommandWriter(ctx context.Context, level int, tags Tags) *LineWriter {
+	return newLineWriter(contextFor(ctx).WithContext(tags), level)
+}
+
+var writerLevels = []struct {
+	level int
+	name  string
+}{{ERROR, "error"}, {WARN, "warn"}, {INFO, "info"}, {DEBUG, "debug"}, {TRACE, "trace"}}
+
+// Levels between the named ones are rounded down, and those outside the range
+// are clamped to trace and error
+func newLineWriter(context logContext, level int) *LineWriter {
+	l := writerLevels[len(writerLevels)-1]
+	for _, candidate := range writerLevels {
+		if level >= candidate.level {
+			l = candidate
+			break
+		}
+	}
+	return &LineWriter{context: context, level: l.level, name: l.name}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	for len(w.buf) >= MaxWriterLineSize {
+		w.line(w.buf[:MaxWriterLineSize])
+		w.buf = w.buf[MaxWriterLineSize:]
+	}
+	// Drop the consumed prefix so buf does not grow unbounded
+	w.buf = append([]byte(nil), w.buf...)
+	return len(p), nil
+}
+
+// Logs the pending frame and partial line, if any
+func (w *LineWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.line(w.buf)
+	w.buf = nil
+	w.emit(w.frame.take())
+	return nil
+}
+
+func (w *LineWriter) line(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	w.lastLine = time.Now()
+	w.delay = WriterFlushDelay
+	if w.idle == nil {
+		w.idle = time.AfterFunc(w.delay, w.flushIdle)
+	} else {
+		w.idle.Reset(w.delay)
+	}
+	if w.frame.continues(line) && w.frame.size()+len(line) < MaxWriterLineSize {
+		w.frame.add(line)
+		return
+	}
+	w.emit(w.frame.take())
+	w.frame.start(line)
+}
+
+// Logs the frame if no line came since the timer was reset, which it may have
+// been while this was waiting for the lock
+func (w *LineWriter) flushIdle() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if time.Since(w.lastLine) >= w.delay {
+		w.emit(w.frame.take())
+	}
+}
+
+func (w *LineWriter) emit(message string) {
+	if strings.TrimSpace(message) == "" || !w.context.enabled(w.level) {
+		return
+	}
+	w.context.Log(w.name, message)
+}
+
+const (
+	frameText = iota // A line and its indented continuation (Java, JS stack traces)
+	frameGoStack
+	framePyStack
+	frameJSON
+)
+
+// Lines grouped into a single record
+type frame struct {
+	kind  int
+	lines []string
+	depth int // Open braces of a JSON frame
+	done  bool
+}
+
+func (f *frame) start(line []byte) {
+	str := string(line)
+	f.lines = []string{str}
+	f.done = false
+	f.depth = 0
+	trimmed := strings.TrimSpace(str)
+	switch {
+	case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["):
+		f.kind = frameJSON
+		f.depth = jsonDepth(str, 0)
+		f.done = f.depth <= 0
+	case strings.HasPrefix(str, "panic: ") || strings.HasPrefix(str, "fatal error: ") || strings.HasPrefix(str, "goroutine "):
+		f.kind = frameGoStack
+	case strings.HasPrefix(str, "Traceback (most recent call last)"):
+		f.kind = framePyStack
+	default:
+		f.kind = frameText
+	}
+}
+
+// Reports whether line belongs to the frame
+func (f *frame) continues(line []byte) bool {
+	if len(f.lines) == 0 || f.done {
+		return false
+	}
+	str := string(line)
+	indented := strings.HasPrefix(str, " ") || strings.HasPrefix(str, "\t")
+	switch f.kind {
+	case frameJSON:
+		return true
+	case frameGoStack:
+		return indented || strings.TrimSpace(str) == "" || strings.HasPrefix(str, "goroutine ") ||
+			strings.HasPrefix(str, "created by ") || strings.HasPrefix(str, "[signal ") ||
+			str == "...additional frames elided..." || goFunctionLine.MatchString(str)
+	case framePyStack:
+		// The exception line closes the traceback
+		f.done = !indented
+		return true
+	}
+	return indented && strings.TrimSpace(str) != "" || strings.HasPrefix(str, "Caused by: ")
+}
+
+// Function line of a Go stack trace, e.g. "main.(*Server).serve(0xc000010000, {0x4b2f20?, 0x5})":
+// a package path, receiver and function name without spaces, and the arguments
+// as hex words, {...} groups or "..."
+var goFunctionLine = regexp.MustCompile(`^[\w./%*\-\[\]]+(\(\*?[\w.\[\], ]+\))?[\w.\-\[\]]*\(((0x[0-9a-f]+\??|\{[^{}]*\}|\.\.\.)(, )?)*\)$`)
+
+func (f *frame) add(line []byte) {
+	str := string(line)
+	f.lines = append(f.lines, str)
+	if f.kind == frameJSON {
+		f.depth = jsonDepth(str, f.depth)
+		f.done = f.depth <= 0
+	}
+}
+
+func (f *frame) size() int {
+	n := 0
+	for _, line := range f.lines {
+		n += len(line) + 1
+	}
+	return n
+}
+
+// Returns the lines of the frame joined, emptying it
+func (f *frame) take() string {
+	message := strings.TrimRight(strings.Join(f.lines, "\n"), "\n")
+	f.lines = nil
+	return message
+}
+
+// Returns depth updated with the brackets opened and closed by line, ignoring
+// those within strings
+func jsonDepth(line string, depth int) int {
+	inString, escaped := false, false
+	for _, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return depth
+}
+
+// Returns a writer logging each line at level with the tags of the context,
+// for components that only accept an io.Writer (http debug output, SDK loggers, ...)
+func (context logContext) Writer(level int) *LineWriter {
+	return newLineWriter(context, level)
+}
+
+// Like logContext.Writer, for the default context
+func Writer(level int) *LineWriter {
+	return defaultContext.Writer(level)
+}
@@ -0,0 +1,119 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterLevels(t *testing.T) {
+	cases := []struct {
+		level    int
+		expected string
+	}{
+		{TRACE - 5, "trace"},
+		{DEBUG, "debug"},
+		{INFO, "info"},
+		{3, "warn"},
+		{CRITIC, "error"},
+		{NONE, "error"},
+	}
+	for _, c := range cases {
+		context, sink := recordingContext()
+		w := context.Writer(c.level)
+		fmt.Fprintln(w, "line")
+		w.Flush()
+		if len(sink.records) != 1 || sink.records[0]["level"] != c.expected {
+			t.Errorf("Level %d: expected %s, got %v", c.level, c.expected, sink.records)
+		}
+	}
+}
+
+func TestLineWriterFraming(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"lines", "one\ntwo\r\n\nthree\n", []string{"one", "two", "three"}},
+		{"partial line", "one\ntw", []string{"one", "tw"}},
+		{"java stack",
+			"Exception in thread \"main\" java.lang.IllegalStateException: boom\n\tat App.run(App.java:10)\nCaused by: java.io.IOException: closed\n\tat Io.read(Io.java:3)\nnext\n",
+			[]string{"Exception in thread \"main\" java.lang.IllegalStateException: boom\n\tat App.run(App.java:10)\nCaused by: java.io.IOException: closed\n\tat Io.read(Io.java:3)", "next"}},
+		{"go panic",
+			"panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d\nexit status 2\n",
+			[]string{"panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d", "exit status 2"}},
+		{"go stack followed by a parenthesized line",
+			"goroutine 7 [running]:\nmain.(*Server).serve(0xc000010000, {0x4b2f20?, 0x5})\n\t/app/server.go:42 +0x1d\ncreated by main.main in goroutine 1\n\t/app/main.go:9 +0x25\nRetrying (attempt 2)\n",
+			[]string{"goroutine 7 [running]:\nmain.(*Server).serve(0xc000010000, {0x4b2f20?, 0x5})\n\t/app/server.go:42 +0x1d\ncreated by main.main in goroutine 1\n\t/app/main.go:9 +0x25", "Retrying (attempt 2)"}},
+		{"python traceback",
+			"Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: boom\nnext\n",
+			[]string{"Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: boom", "next"}},
+		{"json",
+			"{\n  \"text\": \"a } in a string\",\n  \"list\": [1, 2]\n}\nnext\n",
+			[]string{"{\n  \"text\": \"a } in a string\",\n  \"list\": [1, 2]\n}", "next"}},
+		{"single line json", "{\"a\": 1}\n{\"b\": 2}\n", []string{"{\"a\": 1}", "{\"b\": 2}"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			context, sink := recordingContext()
+			w := context.Writer(INFO)
+			// Writes byte by byte so frames and lines span several writes
+			for i := range c.input {
+				w.Write([]byte{c.input[i]})
+			}
+			w.Flush()
+			if len(sink.records) != len(c.expected) {
+				t.Fatalf("Expected %d records, got %d: %v", len(c.expected), len(sink.records), sink.records)
+			}
+			for i, record := range sink.records {
+				if record["message"] != c.expected[i] {
+					t.Errorf("Record %d: expected %q, got %q", i, c.expected[i], record["message"])
+				}
+			}
+		})
+	}
+}
+
+func TestLineWriterSplitsLongLines(t *testing.T) {
+	defer func(size int) { MaxWriterLineSize = size }(MaxWriterLineSize)
+	MaxWriterLineSize = 4
+	context, sink := recordingContext()
+	w := context.Writer(INFO)
+	fmt.Fprint(w, "abcdefghij")
+	w.Flush()
+	expected := []string{"abcd", "efgh", "ij"}
+	if len(sink.records) != len(expected) {
+		t.Fatalf("Expected %d records, got %v", len(expected), sink.records)
+	}
+	for i, record := range sink.records {
+		if record["message"] != expected[i] {
+			t.Errorf("Record %d: expected %q, got %q", i, expected[i], record["message"])
+		}
+	}
+}
+
+func TestLineWriterFlushesWhenIdle(t *testing.T) {
+	defer func(delay time.Duration) { WriterFlushDelay = delay }(WriterFlushDelay)
+	WriterFlushDelay = 10 * time.Millisecond
+	context, sink := recordingContext()
+	w := context.Writer(INFO)
+	fmt.Fprint(w, "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.lock.Lock()
+		records := append([]Tags(nil), sink.records...)
+		w.lock.Unlock()
+		if len(records) > 0 {
+			if len(records) != 1 || !strings.HasSuffix(records[0]["message"].(string), "main.go:5 +0x1d") {
+				t.Errorf("Expected the whole stack in a record, got %v", records)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The idle frame was not logged")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,151 @@
+// Sets up the log and metrics packages in one call:
+//
+//	shutdown, err := logging.Init(logging.Config{
+//		AppName:     "orders",
+//		Environment: "production",
+//		Level:       "info",
+//		Format:      "json",
+//		Metrics:     &log.PushMetricsConfig{},
+//		Tracer:      logging.TRACER_NEWRELIC,
+//		TracerKey:   os.Getenv("NEW_RELIC_LICENSE_KEY"),
+//	})
+//	defer shutdown()
+package logging
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/log"
+	"github.com/gonzalo-mangado/logging/metrics"
+)
+
+// Tracer backends
+const (
+	TRACER_NONE      = ""
+	TRACER_NEWRELIC  = "newrelic"
+	TRACER_ELASTIC   = "elastic"
+	TRACER_OTEL      = "otel"
+	TRACER_HONEYCOMB = "honeycomb"
+)
+
+// Zero fields keep the current setting, so the LOG_* env vars still apply
+type Config struct {
+	AppName     string
+	Environment string
+
+	Level      string // Level name, e.g. "info"
+	Format     string // brackets, ecs, json, logfmt, pretty or stdlib
+	Output     io.Writer
+	GlobalTags log.Tags
+	// Timezone of the timestamps: "UTC" (the default), "Local" or an IANA name
+	Timezone string
+	// Correction added to the host clock when it is known to be skewed
+	ClockOffset time.Duration
+	// Rules escalating, forwarding or alerting on specific records, see log.Policy
+	Policies []log.Policy
+
+	// Metrics are pushed when set; Prefix and Environment default to AppName and Environment
+	Metrics *log.PushMetricsConfig
+	// Interval the runtime metrics (goroutines, heap, GC) are pushed at, 0 disables
+	// them. Requires Metrics.
+	RuntimeMetrics time.Duration
+
+	Tracer      string // One of the TRACER_* constants
+	TracerKey   string // New Relic license or Honeycomb API key
+	TracerDebug bool   // New Relic debug logging
+}
+
+// Configures logging, metrics and tracing and returns the function that flushes
+// and closes them, to be called before the process exits. The configuration is
+// checked and the tracer started before any setting is applied, so nothing
+// changes when an error is returned.
+func Init(config Config) (shutdown func() error, err error) {
+	var level int
+	if config.Level != "" {
+		if level, err = log.ParseLevel(config.Level); err != nil {
+			return nil, err
+		}
+	}
+	var formatter log.Formatter
+	if config.Format != "" {
+		if formatter, err = log.FormatterByName(config.Format); err != nil {
+			return nil, err
+		}
+	}
+	var loc *time.Location
+	if config.Timezone != "" {
+		if loc, err = time.LoadLocation(config.Timezone); err != nil {
+			return nil, fmt.Errorf("Invalid timezone: %s", err)
+		}
+	}
+	if err := log.ValidatePolicies(config.Policies); err != nil {
+		return nil, err
+	}
+	if config.RuntimeMetrics > 0 && config.Metrics == nil {
+		return nil, fmt.Errorf("Runtime metrics need Metrics to be pushed")
+	}
+
+	switch config.Tracer {
+	case TRACER_NONE:
+	case TRACER_NEWRELIC:
+		err = metrics.InitNewRelic(config.TracerDebug, config.Environment, config.AppName, config.TracerKey)
+	case TRACER_ELASTIC:
+		err = metrics.InitElasticAPM(config.Environment, config.AppName)
+	case TRACER_OTEL:
+		metrics.InitOpenTelemetry(config.AppName)
+	case TRACER_HONEYCOMB:
+		err = metrics.InitHoneycomb(config.TracerKey, config.AppName)
+	default:
+		err = fmt.Errorf("Unknown tracer: %s", config.Tracer)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Level != "" {
+		log.SetLevel(level)
+	}
+	if formatter != nil {
+		log.SetFormatter(formatter)
+	}
+	if config.Output != nil {
+		log.SetOutput(config.Output)
+	}
+	if config.GlobalTags != nil {
+		log.SetGlobalTags(config.GlobalTags)
+	}
+	if loc != nil {
+		clock.SetLocation(loc)
+	}
+	if config.ClockOffset != 0 {
+		clock.SetOffset(config.ClockOffset)
+	}
+	if config.Policies != nil {
+		if err := log.SetPolicies(config.Policies); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Metrics != nil {
+		pushConfig := *config.Metrics
+		if pushConfig.Prefix == "" {
+			pushConfig.Prefix = config.AppName
+		}
+		if pushConfig.Environment == "" {
+			pushConfig.Environment = config.Environment
+		}
+		log.ConfigurePushMetrics(pushConfig)
+	}
+
+	stopRuntime := func() {}
+	if config.RuntimeMetrics > 0 {
+		stopRuntime = metrics.CollectRuntime(config.RuntimeMetrics)
+	}
+	return func() error {
+		stopRuntime()
+		return log.Close()
+	}, nil
+}
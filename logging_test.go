@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/log"
+)
+
+func TestInitAppliesNothingOnError(t *testing.T) {
+	defer log.SetLevel(log.Level)
+	log.SetLevel(log.WARN)
+	cases := []struct {
+		name   string
+		config Config
+	}{
+		{"invalid format", Config{Level: "debug", Format: "xml"}},
+		{"invalid timezone", Config{Level: "debug", Timezone: "Mars/Olympus"}},
+		{"invalid policy", Config{Level: "debug", Policies: []log.Policy{{Name: "empty"}}}},
+		{"unknown tracer", Config{Level: "debug", Tracer: "zipkin"}},
+		{"runtime metrics without metrics", Config{Level: "debug", RuntimeMetrics: time.Minute}},
+	}
+	for _, c := range cases {
+		if _, err := Init(c.config); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+		if log.Level != log.WARN {
+			t.Errorf("%s: the level was changed to %d", c.name, log.Level)
+		}
+	}
+}
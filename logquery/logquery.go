@@ -0,0 +1,136 @@
+// Reads files written by the log package back into records, filtered by level,
+// tags and time range, for postmortem scripts.
+package logquery
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/log"
+)
+
+// Zero fields match every record
+type Query struct {
+	MinLevel string            // Level name, e.g. "warn"
+	Tags     map[string]string // Values compared with their %v rendering
+	Since    time.Time
+	Until    time.Time
+	// Tag holding the record time. By default the first of TimeKeys present.
+	TimeKey string
+}
+
+// Tags holding the record time: "@timestamp" written by the ECS formatter,
+// "timestamp" stamped by the HTTP sinks, and "time" for records stamped by
+// the application
+var TimeKeys = []string{"@timestamp", "timestamp", "time"}
+
+// Returns whether record passes every condition of the query. Records without
+// a parseable time do not match a time range.
+func (q Query) Match(record log.Tags) (bool, error) {
+	if q.MinLevel != "" {
+		min, err := log.ParseLevel(q.MinLevel)
+		if err != nil {
+			return false, err
+		}
+		value, ok := record["level"]
+		if !ok {
+			value = record["log.level"] // ECS
+		}
+		level, err := log.ParseLevel(fmt.Sprintf("%v", value))
+		if err != nil || level < min {
+			return false, nil
+		}
+	}
+	for k, v := range q.Tags {
+		if value, ok := record[k]; !ok || fmt.Sprintf("%v", value) != v {
+			return false, nil
+		}
+	}
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		t, ok := q.recordTime(record)
+		if !ok || !q.Since.IsZero() && t.Before(q.Since) || !q.Until.IsZero() && !t.Before(q.Until) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Times are RFC 3339 strings or Unix milliseconds, as the Datadog and New Relic
+// sinks stamp them
+func (q Query) recordTime(record log.Tags) (time.Time, bool) {
+	keys := TimeKeys
+	if q.TimeKey != "" {
+		keys = []string{q.TimeKey}
+	}
+	for _, key := range keys {
+		switch value := record[key].(type) {
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, value)
+			return t, err == nil
+		case float64:
+			return time.UnixMilli(int64(value)), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Calls fn with every record read from r that matches the query, in order.
+// Lines that are not records are skipped. Stops at the first error of fn.
+func Scan(r io.Reader, q Query, fn func(record log.Tags) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		record, err := log.ParseLine(scanner.Text())
+		if err != nil {
+			continue
+		}
+		match, err := q.Match(record)
+		if err != nil {
+			return err
+		}
+		if match {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// Repeatable -tag key=value flag filling Query.Tags:
+//
+//	q := logquery.Query{Tags: logquery.TagFlag{}}
+//	flag.Var(logquery.TagFlag(q.Tags), "tag", "only records with this key=value tag")
+type TagFlag map[string]string
+
+func (tags TagFlag) String() string {
+	return fmt.Sprintf("%v", map[string]string(tags))
+}
+
+func (tags TagFlag) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	tags[kv[0]] = kv[1]
+	return nil
+}
+
+// Returns the records of the file at path that match the query
+func ReadFile(path string, q Query) ([]log.Tags, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []log.Tags
+	err = Scan(file, q, func(record log.Tags) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
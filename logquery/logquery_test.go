@@ -0,0 +1,109 @@
+package logquery
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/log"
+	"github.com/gonzalo-mangado/logging/logtest"
+)
+
+const lines = `{"level":"info","message":"started","time":"2024-01-01T10:00:00Z"}
+[level:warn][message:slow][user:ann][time:2024-01-01T11:00:00Z]
+not a record
+level=error message="failed" user=ann time=2024-01-01T12:00:00Z
+{"level":"debug","message":"no time","user":"bob"}
+`
+
+func messages(t *testing.T, q Query) string {
+	var found []string
+	err := Scan(strings.NewReader(lines), q, func(record log.Tags) error {
+		found = append(found, fmt.Sprintf("%v", record["message"]))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(found, ",")
+}
+
+func TestScan(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		name     string
+		query    Query
+		expected string
+	}{
+		{"all", Query{}, "started,slow,failed,no time"},
+		{"min level", Query{MinLevel: "warn"}, "slow,failed"},
+		{"tags", Query{Tags: map[string]string{"user": "ann"}}, "slow,failed"},
+		{"missing tag", Query{Tags: map[string]string{"user": "carl"}}, ""},
+		{"since", Query{Since: at(11)}, "slow,failed"},
+		{"until is exclusive", Query{Until: at(11)}, "started"},
+		{"range and level", Query{MinLevel: "error", Since: at(10), Until: at(13)}, "failed"},
+		{"time key", Query{TimeKey: "updated", Since: at(0)}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if found := messages(t, c.query); found != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, found)
+			}
+		})
+	}
+}
+
+func TestScanInvalidLevel(t *testing.T) {
+	err := Scan(strings.NewReader(lines), Query{MinLevel: "loud"}, func(log.Tags) error { return nil })
+	if err == nil {
+		t.Error("Invalid level accepted")
+	}
+}
+
+func TestTagFlag(t *testing.T) {
+	tags := TagFlag{}
+	if err := tags.Set("event=a=b"); err != nil || tags["event"] != "a=b" {
+		t.Errorf("Unexpected tags %v, %v", tags, err)
+	}
+	if err := tags.Set("novalue"); err == nil {
+		t.Error("Accepted a flag without value")
+	}
+}
+
+func TestScanFormatterOutput(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	fake := logtest.UseFakeClock(start)
+	defer fake.Restore()
+	buf := new(bytes.Buffer)
+	for _, message := range []string{"first", "second", "third"} {
+		log.ECSFormatter{}.Format(buf, log.Tags{"level": "warn", "message": message})
+		buf.WriteByte('\n')
+		fake.Advance(time.Hour)
+	}
+	// Unix milliseconds, as stamped by the Datadog sink
+	fmt.Fprintf(buf, "{\"level\":\"info\",\"message\":\"stamped\",\"timestamp\":%d}\n", start.Add(90*time.Minute).UnixMilli())
+
+	cases := []struct {
+		name     string
+		query    Query
+		expected string
+	}{
+		{"since", Query{Since: start.Add(time.Hour)}, "second,third,stamped"},
+		{"until", Query{Until: start.Add(time.Hour)}, "first"},
+		{"ecs level", Query{MinLevel: "warn", Since: start}, "first,second,third"},
+	}
+	for _, c := range cases {
+		var found []string
+		Scan(bytes.NewReader(buf.Bytes()), c.query, func(record log.Tags) error {
+			found = append(found, fmt.Sprintf("%v", record["message"]))
+			return nil
+		})
+		if strings.Join(found, ",") != c.expected {
+			t.Errorf("%s: expected %s, got %v", c.name, c.expected, found)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package logtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+// Clock that only moves when told to
+type FakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// Installs a fake clock set at now, until Restore is called
+func UseFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	clock.Set(c)
+	return c
+}
+
+// Reinstalls the real clock
+func (c *FakeClock) Restore() {
+	clock.Set(nil)
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), ch})
+	c.fire()
+	return ch
+}
+
+// Moves the clock forward, firing the channels returned by After that are due
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Waits until n channels returned by After are pending, so the goroutines
+// waiting on them are fired by the next Advance
+func (c *FakeClock) WaitForTimers(n int) {
+	for {
+		c.lock.Lock()
+		pending := len(c.waiters)
+		c.lock.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Must be called with the lock held
+func (c *FakeClock) fire() {
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
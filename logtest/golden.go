@@ -0,0 +1,77 @@
+package logtest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Reports whether the golden files are rewritten instead of compared against:
+// LOGTEST_UPDATE=1 go test, or go test -update in packages defining that flag
+func updateGolden() bool {
+	if os.Getenv("LOGTEST_UPDATE") == "1" {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		update, _ := strconv.ParseBool(f.Value.String())
+		return update
+	}
+	return false
+}
+
+// Tags that change between runs, stripped before comparing against golden files
+var VolatileTags = []string{"time", "timestamp", "duration_ms", "deadline_ms", "hmac", "prev_hmac", "trace.id", "span.id", "seq", "record_id", "connection.id"}
+
+// Renders entries one per line as "level message key=value ...", with sorted
+// tags and without VolatileTags
+func Normalize(entries []Entry) string {
+	volatile := make(map[string]bool, len(VolatileTags))
+	for _, k := range VolatileTags {
+		volatile[k] = true
+	}
+	var out strings.Builder
+	for _, entry := range entries {
+		keys := make([]string, 0, len(entry.Tags))
+		for k := range entry.Tags {
+			if !volatile[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		out.WriteString(entry.Level + " " + entry.Message)
+		for _, k := range keys {
+			fmt.Fprintf(&out, " %s=%+v", k, entry.Tags[k])
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// Compares the normalized captured entries against the golden file at path,
+// or rewrites it when running with LOGTEST_UPDATE=1
+func (hook *Hook) AssertGolden(t testing.TB, path string) {
+	t.Helper()
+	actual := Normalize(hook.Entries())
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Could not create golden file directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("Could not update golden file: %s", err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read golden file (run with LOGTEST_UPDATE=1 to create it): %s", err)
+	}
+	if string(expected) != actual {
+		t.Errorf("Log output does not match golden file %s\n--- expected\n%s--- actual\n%s", path, expected, actual)
+	}
+}
@@ -0,0 +1,105 @@
+package logtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gonzalo-mangado/logging/log"
+)
+
+type Entry struct {
+	Level   string
+	Message string
+	Tags    log.Tags
+}
+
+// Records every emitted entry while it is registered
+type Hook struct {
+	lock          sync.Mutex
+	entries       []Entry
+	previousLevel int
+}
+
+// Registers a hook capturing every record, lowering the log level to TRACE
+// until Release is called
+func Capture() *Hook {
+	hook := &Hook{previousLevel: log.Level}
+	log.SetLevel(log.TRACE)
+	log.AddSink(hook)
+	return hook
+}
+
+// Unregisters the hook and restores the previous log level
+func (hook *Hook) Release() {
+	log.RemoveSink(hook)
+	log.SetLevel(hook.previousLevel)
+}
+
+func (hook *Hook) Write(record log.Tags) error {
+	entry := Entry{Tags: log.Tags{}}
+	for k, v := range record {
+		switch k {
+		case "level":
+			entry.Level = fmt.Sprintf("%v", v)
+		case "message":
+			entry.Message = fmt.Sprintf("%v", v)
+		default:
+			entry.Tags[k] = v
+		}
+	}
+	hook.lock.Lock()
+	hook.entries = append(hook.entries, entry)
+	hook.lock.Unlock()
+	return nil
+}
+
+// Returns a copy of the captured entries
+func (hook *Hook) Entries() []Entry {
+	hook.lock.Lock()
+	defer hook.lock.Unlock()
+	return append([]Entry{}, hook.entries...)
+}
+
+// Discards the captured entries
+func (hook *Hook) Reset() {
+	hook.lock.Lock()
+	hook.entries = nil
+	hook.lock.Unlock()
+}
+
+// Returns whether an entry was captured with the given level, a message
+// containing msgContains and, at least, the given tags
+func (hook *Hook) HasEntry(level string, msgContains string, tags log.Tags) bool {
+	for _, entry := range hook.Entries() {
+		if entry.Matches(level, msgContains, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of entries captured with the given level
+func (hook *Hook) Count(level string) int {
+	count := 0
+	for _, entry := range hook.Entries() {
+		if entry.Level == level {
+			count++
+		}
+	}
+	return count
+}
+
+// Tag values are compared by their printed value
+func (entry Entry) Matches(level string, msgContains string, tags log.Tags) bool {
+	if entry.Level != level || !strings.Contains(entry.Message, msgContains) {
+		return false
+	}
+	for k, v := range tags {
+		actual, ok := entry.Tags[k]
+		if !ok || fmt.Sprintf("%v", actual) != fmt.Sprintf("%v", v) {
+			return false
+		}
+	}
+	return true
+}
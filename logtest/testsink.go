@@ -0,0 +1,38 @@
+package logtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonzalo-mangado/logging/log"
+)
+
+// Sink writing the records to t, tagged with the test name. As with t.Log, the
+// output is only shown for failing tests (or with -v); error, critic and fatal
+// records fail the test. Used with log.WithOutput:
+//
+//	logger := log.WithOutput(logtest.NewTestSink(t))
+func NewTestSink(t testing.TB) log.Sink {
+	return testSink{t}
+}
+
+type testSink struct {
+	t testing.TB
+}
+
+func (sink testSink) Write(record log.Tags) error {
+	sink.t.Helper()
+	tagged := log.Tags{"test": sink.t.Name()}
+	for k, v := range record {
+		tagged[k] = v
+	}
+	buf := new(bytes.Buffer)
+	log.BracketsFormatter{}.Format(buf, tagged)
+	switch record["level"] {
+	case "error", "critic", "fatal":
+		sink.t.Errorf("%s", buf)
+	default:
+		sink.t.Log(buf.String())
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package logtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gonzalo-mangado/logging/log"
+)
+
+// Records what is logged to it instead of the test
+type fakeT struct {
+	testing.TB
+	logs   []string
+	errors []string
+}
+
+func (t *fakeT) Helper()      {}
+func (t *fakeT) Name() string { return "TestOrders" }
+
+func (t *fakeT) Log(args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprint(args...))
+}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNewTestSink(t *testing.T) {
+	fake := &fakeT{TB: t}
+	logger := log.WithOutput(NewTestSink(fake))
+	logger.Debug("Loading")
+	logger.Error("Failed")
+	if len(fake.logs) != 1 || !strings.Contains(fake.logs[0], "Loading") || !strings.Contains(fake.logs[0], "TestOrders") {
+		t.Errorf("Expected the debug record logged with the test name, got %v", fake.logs)
+	}
+	if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "Failed") {
+		t.Errorf("Expected the error record to fail the test, got %v", fake.errors)
+	}
+}
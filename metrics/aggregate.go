@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+type aggregatedCounter struct {
+	name  string
+	value float64
+	tags  []string
+}
+
+type counterAggregator struct {
+	lock     sync.Mutex
+	counters map[string]*aggregatedCounter
+	done     chan struct{}
+	stopped  chan struct{} // Closed when run returns
+}
+
+var aggregator *counterAggregator
+var aggregatorLock sync.RWMutex
+
+// Sums SIMPLE metrics with the same name and tags locally and pushes the totals
+// once per window, instead of one backend call per metric. A window of 0
+// flushes pending counters and disables aggregation.
+func AggregateCounters(window time.Duration) {
+	aggregatorLock.Lock()
+	previous := aggregator
+	aggregator = nil
+	if window > 0 {
+		aggregator = &counterAggregator{counters: map[string]*aggregatedCounter{}, done: make(chan struct{}), stopped: make(chan struct{})}
+		go aggregator.run(window)
+	}
+	aggregatorLock.Unlock()
+	if previous != nil {
+		close(previous.done)
+		<-previous.stopped
+		previous.flush()
+	}
+}
+
+// Pushes the counters aggregated so far
+func FlushCounters() {
+	aggregatorLock.RLock()
+	defer aggregatorLock.RUnlock()
+	if aggregator != nil {
+		aggregator.flush()
+	}
+}
+
+// Adds to the aggregated counter, returns false when aggregation is disabled
+func aggregate(name string, value float64, tags []string) bool {
+	aggregatorLock.RLock()
+	defer aggregatorLock.RUnlock()
+	if aggregator == nil {
+		return false
+	}
+	aggregator.add(name, value, tags)
+	return true
+}
+
+func (a *counterAggregator) add(name string, value float64, tags []string) {
+	sort.Strings(tags)
+	key := name + "|" + strings.Join(tags, ",")
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if counter, ok := a.counters[key]; ok {
+		counter.value += value
+	} else {
+		a.counters[key] = &aggregatedCounter{name, value, tags}
+	}
+}
+
+func (a *counterAggregator) flush() {
+	a.lock.Lock()
+	counters := a.counters
+	a.counters = map[string]*aggregatedCounter{}
+	a.lock.Unlock()
+	for _, counter := range counters {
+		record(SIMPLE, counter.name, counter.value, UNIT_COUNT, counter.tags)
+	}
+}
+
+func (a *counterAggregator) run(window time.Duration) {
+	defer close(a.stopped)
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-clock.After(window):
+			a.flush()
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAggregateCounters(t *testing.T) {
+	b := useRecordingBackend(t)
+	c := useManualClock(t)
+	AggregateCounters(1)
+	defer AggregateCounters(0)
+
+	aggregate("requests", 1, []string{"b:2", "a:1"})
+	aggregate("requests", 2, []string{"a:1", "b:2"})
+	aggregate("requests", 1, []string{"a:2"})
+	aggregate("errors", 1, nil)
+	if recorded := b.recorded(); len(recorded) != 0 {
+		t.Fatalf("Recorded before the window ended: %v", recorded)
+	}
+
+	// Wait for the aggregator to start its timer before firing it
+	eventually(t, func() bool {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return len(c.timers) > 0
+	})
+	c.tick()
+	expected := "[S errors 1  S requests 1 a:2 S requests 3 a:1,b:2]"
+	eventually(t, func() bool { return fmt.Sprint(b.recorded()) == expected })
+
+	aggregate("requests", 5, nil)
+	AggregateCounters(0)
+	if recorded := b.recorded(); len(recorded) != 4 || recorded[3] != "S requests 5 " {
+		t.Errorf("Pending counters not flushed when disabled: %v", recorded)
+	}
+	if aggregate("requests", 1, nil) {
+		t.Error("Aggregated after disabling")
+	}
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mercadolibre/go-meli-toolkit/godog"
+)
+
+// Destination of the pushed metrics. metricType is FULL, SIMPLE or COMPOUND.
+type Backend interface {
+	Record(metricType string, name string, value float64, tags []string) error
+}
+
+// Implemented by backends that record the unit of the values
+type UnitBackend interface {
+	Backend
+	RecordUnit(metricType string, name string, value float64, unit Unit, tags []string) error
+}
+
+// Datadog through godog, which never reports failures
+type godogBackend struct{}
+
+func (godogBackend) Record(metricType string, name string, value float64, tags []string) error {
+	switch metricType {
+	case FULL:
+		godog.RecordFullMetric(name, value, tags...)
+	case SIMPLE:
+		godog.RecordSimpleMetric(name, value, tags...)
+	case COMPOUND:
+		godog.RecordCompoundMetric(name, value, tags...)
+	default:
+		return fmt.Errorf("Unkown metric type: %s", metricType)
+	}
+	return nil
+}
+
+var backend Backend = godogBackend{}
+
+// Selects the backend PushMetric sends metrics to, nil restores godog
+func UseBackend(b Backend) {
+	if b == nil {
+		b = godogBackend{}
+	}
+	backend = b
+}
+
+type deadLetter struct {
+	metricType string
+	name       string
+	value      float64
+	unit       Unit
+	tags       []string
+	seq        uint64 // Identifies the letter once others were evicted before it
+}
+
+// Metrics the backend failed to record, replayed once it records one again
+type deadLetterBuffer struct {
+	lock      sync.Mutex
+	letters   []deadLetter
+	size      int
+	dropped   int
+	replaying bool
+	seq       uint64
+}
+
+var deadLetters = &deadLetterBuffer{size: 10000}
+
+// Sets how many failed metrics are kept for replay; older ones are dropped
+// beyond it, and 0 disables the buffer
+func SetDeadLetterSize(size int) {
+	deadLetters.lock.Lock()
+	defer deadLetters.lock.Unlock()
+	deadLetters.size = size
+	if len(deadLetters.letters) > size {
+		deadLetters.dropped += len(deadLetters.letters) - size
+		deadLetters.letters = deadLetters.letters[len(deadLetters.letters)-size:]
+	}
+}
+
+// Returns the number of metrics waiting for replay and dropped so far
+func DeadLetters() (pending int, dropped int) {
+	deadLetters.lock.Lock()
+	defer deadLetters.lock.Unlock()
+	return len(deadLetters.letters), deadLetters.dropped
+}
+
+// Records a metric in the backend, keeping it for replay if it fails. Returns
+// an error only if the metric is lost.
+func record(metricType string, name string, value float64, unit Unit, tags []string) error {
+	if err := recordUnit(metricType, name, value, unit, tags); err != nil {
+		if !deadLetters.add(deadLetter{metricType: metricType, name: name, value: value, unit: unit, tags: tags}) {
+			return fmt.Errorf("Could not record metric %s, dead-letter buffer full: %s", name, err)
+		}
+		return nil
+	}
+	deadLetters.replayAsync()
+	return nil
+}
+
+func recordUnit(metricType string, name string, value float64, unit Unit, tags []string) error {
+	if b, ok := backend.(UnitBackend); ok && unit != "" {
+		return b.RecordUnit(metricType, name, value, unit, tags)
+	}
+	return backend.Record(metricType, name, value, tags)
+}
+
+func (buffer *deadLetterBuffer) add(letter deadLetter) bool {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	if buffer.size <= 0 {
+		buffer.dropped++
+		return false
+	}
+	if len(buffer.letters) >= buffer.size {
+		buffer.letters = buffer.letters[1:]
+		buffer.dropped++
+	}
+	buffer.seq++
+	letter.seq = buffer.seq
+	buffer.letters = append(buffer.letters, letter)
+	return true
+}
+
+func (buffer *deadLetterBuffer) replayAsync() {
+	if buffer.claim() {
+		go buffer.replay()
+	}
+}
+
+// Returns whether the caller may replay, false while another replay runs
+func (buffer *deadLetterBuffer) claim() bool {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	if len(buffer.letters) == 0 || buffer.replaying {
+		return false
+	}
+	buffer.replaying = true
+	return true
+}
+
+// Records the buffered metrics, stopping at the first failure. Must be called
+// once claim succeeded.
+func (buffer *deadLetterBuffer) replay() {
+	defer func() {
+		buffer.lock.Lock()
+		buffer.replaying = false
+		buffer.lock.Unlock()
+	}()
+	for {
+		buffer.lock.Lock()
+		if len(buffer.letters) == 0 {
+			buffer.lock.Unlock()
+			return
+		}
+		letter := buffer.letters[0]
+		buffer.lock.Unlock()
+		if err := recordUnit(letter.metricType, letter.name, letter.value, letter.unit, letter.tags); err != nil {
+			return
+		}
+		buffer.lock.Lock()
+		// The letter may have been evicted by add while it was being recorded
+		if len(buffer.letters) > 0 && buffer.letters[0].seq == letter.seq {
+			buffer.letters = buffer.letters[1:]
+		}
+		buffer.lock.Unlock()
+	}
+}
+
+// Records the metrics kept after backend failures, stopping at the first
+// failure. Returns at once if a replay is already running.
+func ReplayDeadLetters() {
+	if deadLetters.claim() {
+		deadLetters.replay()
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+)
+
+func useDeadLetterSize(t *testing.T, size int) {
+	deadLetters = &deadLetterBuffer{size: size}
+	t.Cleanup(func() { deadLetters = &deadLetterBuffer{size: 10000} })
+}
+
+func TestDeadLettersBound(t *testing.T) {
+	b := useRecordingBackend(t)
+	useDeadLetterSize(t, 2)
+	b.setFailing(true)
+	for i := 0; i < 3; i++ {
+		if err := record(FULL, fmt.Sprintf("m%d", i), 1, UNIT_NONE, nil); err != nil {
+			t.Errorf("Metric %d lost: %s", i, err)
+		}
+	}
+	if pending, dropped := DeadLetters(); pending != 2 || dropped != 1 {
+		t.Errorf("Expected 2 pending and 1 dropped, got %d and %d", pending, dropped)
+	}
+
+	SetDeadLetterSize(1)
+	if pending, dropped := DeadLetters(); pending != 1 || dropped != 2 {
+		t.Errorf("Expected 1 pending and 2 dropped after shrinking, got %d and %d", pending, dropped)
+	}
+	SetDeadLetterSize(0)
+	if err := record(FULL, "lost", 1, UNIT_NONE, nil); err == nil {
+		t.Error("No error for a metric lost with the buffer disabled")
+	}
+}
+
+func TestDeadLettersReplay(t *testing.T) {
+	b := useRecordingBackend(t)
+	useDeadLetterSize(t, 10)
+	b.setFailing(true)
+	record(FULL, "first", 1, UNIT_NONE, nil)
+	record(FULL, "second", 2, UNIT_NONE, nil)
+
+	ReplayDeadLetters()
+	if pending, _ := DeadLetters(); pending != 2 {
+		t.Errorf("Replay with the backend down removed letters: %d pending", pending)
+	}
+
+	b.setFailing(false)
+	// A successful record replays the letters in the background
+	record(FULL, "third", 3, UNIT_NONE, nil)
+	eventually(t, func() bool {
+		pending, _ := DeadLetters()
+		return pending == 0
+	})
+	if recorded := fmt.Sprint(b.recorded()); recorded != "[F first 1  F second 2  F third 3 ]" {
+		t.Errorf("Unexpected metrics %s", recorded)
+	}
+}
+
+// Backend calling a hook before recording each metric
+type hookBackend struct {
+	recordingBackend
+	before func(name string)
+}
+
+func (b *hookBackend) Record(metricType string, name string, value float64, tags []string) error {
+	b.before(name)
+	return b.recordingBackend.Record(metricType, name, value, tags)
+}
+
+func TestDeadLettersReplayRace(t *testing.T) {
+	cases := []struct {
+		name     string
+		claimed  bool
+		evict    bool
+		expected string
+	}{
+		{"replay", false, false, "[F first 1  F second 2 ]"},
+		{"replay already running", true, false, "[]"},
+		{"head evicted while recording", false, true, "[F evicting 3  F first 1  F second 2 ]"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			useDeadLetterSize(t, 2)
+			deadLetters.add(deadLetter{metricType: FULL, name: "first", value: 1})
+			deadLetters.add(deadLetter{metricType: FULL, name: "second", value: 2})
+			deadLetters.replaying = c.claimed
+			b := &hookBackend{before: func(name string) {
+				if c.evict && name == "first" {
+					deadLetters.add(deadLetter{metricType: FULL, name: "evicting", value: 3})
+				}
+			}}
+			UseBackend(b)
+			defer UseBackend(nil)
+			ReplayDeadLetters()
+			if recorded := fmt.Sprint(b.recorded()); recorded != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, recorded)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Catalog formats
+const (
+	CATALOG_JSON     = "json"
+	CATALOG_MARKDOWN = "markdown"
+)
+
+// Writes the registered metrics with their type, tags, unit and description,
+// for documentation and for checking dashboards against what the code emits
+func ExportCatalog(w io.Writer, format string) error {
+	defs := Definitions()
+	switch format {
+	case CATALOG_JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(defs)
+	case CATALOG_MARKDOWN:
+		var b strings.Builder
+		b.WriteString("| Metric | Type | Unit | Tags | Description |\n")
+		b.WriteString("|---|---|---|---|---|\n")
+		for _, def := range defs {
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", def.Name, typeName(def.Type), def.Unit,
+				strings.Join(def.Tags, ", "), strings.ReplaceAll(def.Description, "|", "\\|"))
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	return fmt.Errorf("Unknown catalog format: %s", format)
+}
+
+func typeName(metricType string) string {
+	switch metricType {
+	case FULL:
+		return "full"
+	case SIMPLE:
+		return "simple"
+	case COMPOUND:
+		return "compound"
+	case ERROR:
+		return "error"
+	}
+	return metricType
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+)
+
+var dryRun = false
+
+var dryRunLogger = func(metric Tags) {
+	stderr.Logf("debug", "Metric dry run: %v", metric)
+}
+
+func init() {
+	if value := os.Getenv("METRICS_DRY_RUN"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			stderr.Logf("error", "Invalid METRICS_DRY_RUN %q: must be a boolean", value)
+			return
+		}
+		DryRun(enabled)
+	}
+}
+
+// When enabled, PushMetric validates metrics and logs what it would send instead
+// of sending it, so instrumentation can be checked locally and in CI. Also set
+// by the METRICS_DRY_RUN env var.
+func DryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// Sets the function that logs dry run metrics. The log package installs one
+// logging them at DEBUG.
+func SetDryRunLogger(logger func(metric Tags)) {
+	dryRunLogger = logger
+}
+
+// Returns an error describing why a metric would be rejected
+func validateMetric(name string, metric Metric, tags Tags) error {
+	switch metric.metricType {
+	case FULL, SIMPLE, COMPOUND, ERROR:
+	default:
+		return fmt.Errorf("Unkown metric type: %s", metric.metricType)
+	}
+	if metric.Name == "" {
+		return fmt.Errorf("Metric name is empty")
+	}
+	if math.IsNaN(metric.Value) || math.IsInf(metric.Value, 0) {
+		return fmt.Errorf("Metric %s has an invalid value: %v", name, metric.Value)
+	}
+	for k := range tags {
+		if k == "" {
+			return fmt.Errorf("Metric %s has a tag with an empty name", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.elastic.co/apm"
+)
+
+var ElasticTracer *apm.Tracer
+
+// Initializes the Elastic APM agent and selects it as the transactions backend.
+// Server URL and secret token are read by the agent from ELASTIC_APM_* env vars.
+func InitElasticAPM(environment string, appName string) error {
+	t, err := apm.NewTracerOptions(apm.TracerOptions{
+		ServiceName:        appName,
+		ServiceEnvironment: environment,
+	})
+	if err != nil {
+		return fmt.Errorf("Could not create elastic apm agent: %s", err)
+	}
+	ElasticTracer = t
+	UseTracer(elasticTracer{})
+	return nil
+}
+
+// Middleware to use with Elastic APM
+func ElasticAPM() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tx := ElasticTracer.StartTransaction(c.Request.Method+" "+c.Request.URL.Path, "request")
+		defer tx.End()
+		c.Request = c.Request.WithContext(apm.ContextWithTransaction(c.Request.Context(), tx))
+		c.Set("APM_TX", tx)
+		c.Next()
+		tx.Result = "HTTP " + strconv.Itoa(c.Writer.Status())
+	}
+}
+
+func elasticTraceIDs(ctx context.Context) (string, string) {
+	if span := apm.SpanFromContext(ctx); span != nil {
+		tc := span.TraceContext()
+		return tc.Trace.String(), tc.Span.String()
+	}
+	if tx := apm.TransactionFromContext(ctx); tx != nil {
+		tc := tx.TraceContext()
+		return tc.Trace.String(), tc.Span.String()
+	}
+	return "", ""
+}
+
+type elasticTracer struct{}
+
+func (elasticTracer) StartTransaction(name string) TracerTransaction {
+	return elasticTransaction{ElasticTracer.StartTransaction(name, "custom")}
+}
+
+func (elasticTracer) Middleware() gin.HandlerFunc {
+	return ElasticAPM()
+}
+
+type elasticTransaction struct {
+	tx *apm.Transaction
+}
+
+func (trx elasticTransaction) StartSegment(name string) TracerSegment {
+	return elasticSegment{trx.tx.StartSpan(name, "custom", nil)}
+}
+
+func (trx elasticTransaction) NoticeError(err error) {
+	e := ElasticTracer.NewError(err)
+	e.SetTransaction(trx.tx)
+	e.Send()
+}
+
+func (trx elasticTransaction) TraceIDs() (string, string) {
+	tc := trx.tx.TraceContext()
+	return tc.Trace.String(), tc.Span.String()
+}
+
+func (trx elasticTransaction) End() {
+	trx.tx.End()
+}
+
+func (trx elasticTransaction) Discard() {
+	trx.tx.Discard()
+}
+
+type elasticSegment struct {
+	span *apm.Span
+}
+
+func (seg elasticSegment) End() {
+	seg.span.End()
+}
+
+func (seg elasticSegment) AddAttribute(key string, value interface{}) {
+	seg.span.Context.SetLabel(key, value)
+}
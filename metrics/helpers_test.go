@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+// Backend keeping the recorded metrics, failing while failing is set
+type recordingBackend struct {
+	lock    sync.Mutex
+	metrics []string
+	failing bool
+}
+
+func (b *recordingBackend) Record(metricType string, name string, value float64, tags []string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.failing {
+		return errors.New("backend down")
+	}
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	b.metrics = append(b.metrics, fmt.Sprintf("%s %s %v %s", metricType, name, value, strings.Join(sorted, ",")))
+	return nil
+}
+
+func (b *recordingBackend) setFailing(failing bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failing = failing
+}
+
+// Returns the metrics recorded so far, sorted
+func (b *recordingBackend) recorded() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	sorted := append([]string(nil), b.metrics...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func useRecordingBackend(t *testing.T) *recordingBackend {
+	b := &recordingBackend{}
+	UseBackend(b)
+	t.Cleanup(func() { UseBackend(nil) })
+	return b
+}
+
+// Clock whose timers fire when tick is called
+type manualClock struct {
+	lock   sync.Mutex
+	timers []chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return time.Unix(0, 0)
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	timer := make(chan time.Time, 1)
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Fires the pending timers
+func (c *manualClock) tick() {
+	c.lock.Lock()
+	timers := c.timers
+	c.timers = nil
+	c.lock.Unlock()
+	for _, timer := range timers {
+		timer <- time.Unix(0, 0)
+	}
+}
+
+func useManualClock(t *testing.T) *manualClock {
+	c := &manualClock{}
+	clock.Set(c)
+	t.Cleanup(func() { clock.Set(nil) })
+	return c
+}
+
+// Waits up to a second for cond to hold
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gonzalo-mangado/logging/clock"
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+var HoneycombClient *libhoney.Client
+
+// Client created by InitHoneycomb, closed by Shutdown
+var ownedHoneycombClient *libhoney.Client
+
+// Selects Honeycomb as the transactions backend. Each transaction is sent as a
+// single event carrying its duration and the total duration of each segment.
+func InitHoneycomb(apiKey string, dataset string) error {
+	client, err := libhoney.NewClient(libhoney.ClientConfig{APIKey: apiKey, Dataset: dataset})
+	if err != nil {
+		return fmt.Errorf("Could not create honeycomb client: %s", err)
+	}
+	HoneycombClient = client
+	ownedHoneycombClient = client
+	UseTracer(honeycombTracer{})
+	return nil
+}
+
+// Middleware to use with Honeycomb
+func Honeycomb() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trx := newHoneycombTransaction(c.Request.Method + " " + c.Request.URL.Path)
+		trx.addField("http.method", c.Request.Method)
+		trx.addField("http.path", c.Request.URL.Path)
+		c.Next()
+		trx.addField("http.status", strconv.Itoa(c.Writer.Status()))
+		trx.End()
+	}
+}
+
+type honeycombTracer struct{}
+
+func (honeycombTracer) StartTransaction(name string) TracerTransaction {
+	return newHoneycombTransaction(name)
+}
+
+func (honeycombTracer) Middleware() gin.HandlerFunc {
+	return Honeycomb()
+}
+
+type honeycombTransaction struct {
+	start  time.Time
+	lock   sync.Mutex
+	fields map[string]interface{}
+}
+
+func newHoneycombTransaction(name string) *honeycombTransaction {
+	return &honeycombTransaction{start: clock.Now(), fields: map[string]interface{}{"name": name}}
+}
+
+func (trx *honeycombTransaction) addField(name string, value interface{}) {
+	trx.lock.Lock()
+	trx.fields[name] = value
+	trx.lock.Unlock()
+}
+
+func (trx *honeycombTransaction) StartSegment(name string) TracerSegment {
+	return &honeycombSegment{trx, name, clock.Now()}
+}
+
+func (trx *honeycombTransaction) NoticeError(err error) {
+	trx.lock.Lock()
+	count, _ := trx.fields["errors"].(int)
+	trx.fields["errors"] = count + 1
+	trx.fields["error"] = err.Error()
+	trx.lock.Unlock()
+}
+
+func (trx *honeycombTransaction) End() {
+	event := HoneycombClient.NewEvent()
+	event.Timestamp = trx.start
+	trx.lock.Lock()
+	event.Add(trx.fields)
+	trx.lock.Unlock()
+	event.AddField("duration_ms", ElapsedMilliseconds(trx.start))
+	event.Send()
+}
+
+func (trx *honeycombTransaction) Discard() {}
+
+type honeycombSegment struct {
+	trx   *honeycombTransaction
+	name  string
+	start time.Time
+}
+
+func (seg *honeycombSegment) AddAttribute(key string, value interface{}) {
+	seg.trx.lock.Lock()
+	seg.trx.fields["segment."+seg.name+"."+key] = value
+	seg.trx.lock.Unlock()
+}
+
+// Segments with the same name add up their durations
+func (seg *honeycombSegment) End() {
+	field := "segment." + seg.name + ".duration_ms"
+	elapsed := ElapsedMilliseconds(seg.start)
+	seg.trx.lock.Lock()
+	total, _ := seg.trx.fields[field].(float64)
+	seg.trx.fields[field] = total + elapsed
+	seg.trx.lock.Unlock()
+}
@@ -1,16 +1,16 @@
 package metrics
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gonzalo-mangado/logging/clock"
 	"github.com/gonzalo-mangado/logging/format"
+	"github.com/gonzalo-mangado/logging/internal/stderr"
 	"github.com/mercadolibre/go-meli-toolkit/gingonic/mlhandlers"
-	"github.com/mercadolibre/go-meli-toolkit/godog"
-	newrelic "github.com/newrelic/go-agent"
 )
 
 type Metric struct {
@@ -18,6 +18,7 @@ type Metric struct {
 	Name       string
 	Value      float64
 	tags       Tags
+	Unit       Unit
 }
 
 type Tags map[string]interface{}
@@ -27,11 +28,15 @@ type Metrics struct {
 }
 
 type Transaction struct {
-	nrTrx newrelic.Transaction
+	trx     TracerTransaction
+	name    string
+	sampled bool
+	errored bool
+	labels  []string
+	// Labels of the goroutine before the transaction labeled it, restored by End
+	profileCtx context.Context
 }
 
-var NewRelicApp newrelic.Application
-
 const (
 	FULL     = "F"
 	SIMPLE   = "S"
@@ -52,127 +57,242 @@ func DefaultTags(tags Tags) {
 
 // Returns a metric of type "full"
 func (metrics Metrics) Full(name string, value float64, tags ...Tags) Metrics {
-	return Metrics{append(metrics.Values, Metric{FULL, name, value, mergeTags(tags)})}
+	return Metrics{append(metrics.Values, Metric{FULL, name, value, mergeTags(tags), ""})}
 }
 
 // Returns a metric of type "simple"
 func (metrics Metrics) Simple(name string, value float64, tags ...Tags) Metrics {
-	return Metrics{append(metrics.Values, Metric{SIMPLE, name, value, mergeTags(tags)})}
+	return Metrics{append(metrics.Values, Metric{SIMPLE, name, value, mergeTags(tags), ""})}
 }
 
 // Returns a metric of type "compound"
 func (metrics Metrics) Compound(name string, value float64, tags ...Tags) Metrics {
-	return Metrics{append(metrics.Values, Metric{COMPOUND, name, value, mergeTags(tags)})}
+	return Metrics{append(metrics.Values, Metric{COMPOUND, name, value, mergeTags(tags), ""})}
 }
 
 // Returns a metric of type "simple" with a value of 1
 func (metrics Metrics) Counter(name string, tags ...Tags) Metrics {
-	return Metrics{append(metrics.Values, Metric{SIMPLE, name, float64(1), mergeTags(tags)})}
+	return Metrics{append(metrics.Values, Metric{SIMPLE, name, float64(1), mergeTags(tags), ""})}
 }
 
 // Returns a metric of type "simple" with a value of 1
 func (metrics Metrics) Error(name string, tags ...Tags) Metrics {
-	return Metrics{append(metrics.Values, Metric{ERROR, name, float64(1), mergeTags(tags)})}
+	return Metrics{append(metrics.Values, Metric{ERROR, name, float64(1), mergeTags(tags), ""})}
+}
+
+// Sets the unit of the last metric, e.g. metrics.Full("latency", ms).WithUnit(metrics.UNIT_MS)
+func (metrics Metrics) WithUnit(unit Unit) Metrics {
+	if len(metrics.Values) > 0 {
+		values := append([]Metric(nil), metrics.Values...)
+		values[len(values)-1].Unit = unit
+		metrics.Values = values
+	}
+	return metrics
 }
 
 // Returns a metric of type "full"
 func Full(name string, value float64, tags ...Tags) Metrics {
-	return Metrics{[]Metric{{FULL, name, value, mergeTags(tags)}}}
+	return Metrics{[]Metric{{FULL, name, value, mergeTags(tags), ""}}}
 }
 
 // Returns a metric of type "simple"
 func Simple(name string, value float64, tags ...Tags) Metrics {
-	return Metrics{[]Metric{{SIMPLE, name, value, mergeTags(tags)}}}
+	return Metrics{[]Metric{{SIMPLE, name, value, mergeTags(tags), ""}}}
 }
 
 // Returns a metric of type "error"
 func Error(name string, tags ...Tags) Metrics {
-	return Metrics{[]Metric{{ERROR, name, float64(1), mergeTags(tags)}}}
+	return Metrics{[]Metric{{ERROR, name, float64(1), mergeTags(tags), ""}}}
 }
 
 // Returns a metric of type "compound"
 func Compound(name string, value float64, tags ...Tags) Metrics {
-	return Metrics{[]Metric{{COMPOUND, name, value, mergeTags(tags)}}}
+	return Metrics{[]Metric{{COMPOUND, name, value, mergeTags(tags), ""}}}
 }
 
 // Returns a metric of type "simple" with a value of 1
 func Counter(name string, tags ...Tags) Metrics {
-	return Metrics{[]Metric{{SIMPLE, name, float64(1), mergeTags(tags)}}}
+	return Metrics{[]Metric{{SIMPLE, name, float64(1), mergeTags(tags), ""}}}
 }
 
 // Pushes a metric
 func PushMetric(metric Metric, trx *Transaction, tags ...Tags) error {
 	name := namePrefix + "." + metric.Name
-	strTags := defaultTags.Merge(mergeTags(tags)).asMetricTags()
+	allTags := defaultTags.Merge(metric.tags).Merge(mergeTags(tags))
+	if err := validateMetric(name, metric, allTags); err != nil {
+		return err
+	}
+	if err := checkRegistered(metric); err != nil {
+		return err
+	}
+	if dryRun {
+		dryRunLogger(Tags{"metric.name": name, "metric.type": metric.metricType, "metric.value": metric.Value, "metric.display": metric.Unit.Format(metric.Value), "metric.unit": string(metric.Unit), "metric.tags": allTags})
+		return nil
+	}
+	strTags := allTags.asMetricTags()
 	switch metric.metricType {
-	case FULL:
-		godog.RecordFullMetric(name, metric.Value, strTags...)
 	case SIMPLE:
-		godog.RecordSimpleMetric(name, metric.Value, strTags...)
-	case COMPOUND:
-		godog.RecordCompoundMetric(name, metric.Value, strTags...)
+		if aggregate(name, metric.Value, strTags) {
+			return nil
+		}
 	case ERROR:
 		if trx != nil {
 			trx.NoticeError(name)
 		}
-		godog.RecordSimpleMetric(name, float64(1), strTags...)
-	default:
-		return fmt.Errorf("Unkown metric type: %s", metric.metricType)
+		return record(SIMPLE, name, float64(1), metric.Unit, strTags)
 	}
-	return nil
+	return record(metric.metricType, name, metric.Value, metric.Unit, strTags)
 }
 
 func GingonicHandlers() []gin.HandlerFunc {
-	return []gin.HandlerFunc{mlhandlers.Datadog(), NewRelic()}
+	return GingonicHandlersWith(HandlerOptions{})
+}
+
+// Composition of the handlers returned by GingonicHandlersWith
+type HandlerOptions struct {
+	DisableDatadog     bool
+	DisableTracer      bool
+	DisableRequestTags bool
+	// Requests to these paths (e.g. "/ping") skip the Datadog, tracer and access log handlers
+	SkipPaths []string
+	// Optional handlers from other packages, e.g. log.AccessLog(log.ACCESS_TAGS) and gin.Recovery()
+	AccessLog gin.HandlerFunc
+	Recovery  gin.HandlerFunc
+}
+
+// Returns the recovery, Datadog, tracer, request tags and access log handlers
+// selected by options
+func GingonicHandlersWith(options HandlerOptions) []gin.HandlerFunc {
+	var handlers []gin.HandlerFunc
+	if options.Recovery != nil {
+		handlers = append(handlers, options.Recovery)
+	}
+	if !options.DisableDatadog {
+		handlers = append(handlers, skipPaths(mlhandlers.Datadog(), options.SkipPaths))
+	}
+	if !options.DisableTracer {
+		handlers = append(handlers, skipPaths(tracer.Middleware(), options.SkipPaths))
+	}
+	if !options.DisableRequestTags {
+		handlers = append(handlers, RequestTags())
+	}
+	if options.AccessLog != nil {
+		handlers = append(handlers, skipPaths(options.AccessLog, options.SkipPaths))
+	}
+	return handlers
 }
 
-func InitNewRelic(debug bool, environment string, appName string, appKey string) error {
-	fmt.Println(environment)
-	config := newrelic.NewConfig(fmt.Sprintf("%s.%s", environment, appName), appKey)
-	if debug {
-		config.Logger = newrelic.NewDebugLogger(os.Stdout)
+func skipPaths(handler gin.HandlerFunc, paths []string) gin.HandlerFunc {
+	if len(paths) == 0 {
+		return handler
+	}
+	skip := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		skip[path] = true
 	}
-	if app, err := newrelic.NewApplication(config); err != nil {
-		return fmt.Errorf("Could not create newrelic agent: %s", err)
-	} else {
-		NewRelicApp = app
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		handler(c)
 	}
-	return nil
 }
 
 // Helpers
 
+// Deprecated: use ElapsedMinutes
 func MinutesSince(t time.Time) float64 {
-	return time.Since(t).Minutes()
+	return ElapsedMinutes(t)
+}
+
+func ElapsedMinutes(t time.Time) float64 {
+	return clock.Since(t).Minutes()
+}
+
+func ElapsedSeconds(t time.Time) float64 {
+	return format.Seconds(clock.Since(t))
 }
 
 func ElapsedMilliseconds(t time.Time) float64 {
-	return format.Milliseconds(time.Since(t))
+	return format.Milliseconds(clock.Since(t))
+}
+
+// Returns the time elapsed since t in the given unit, e.g. Since(start, time.Millisecond)
+func Since(t time.Time, unit time.Duration) float64 {
+	return float64(clock.Since(t)) / float64(unit)
+}
+
+// Returns a function reporting the time elapsed since StartTimer was called.
+// It reads the monotonic clock directly, ignoring clock.Set, so it is suited
+// for benchmarks where wall clock adjustments would skew results.
+func StartTimer() func() time.Duration {
+	start := time.Now()
+	return func() time.Duration {
+		return time.Since(start)
+	}
 }
 
 func Trx(id string) *Transaction {
-	nrTrx := NewRelicApp.StartTransaction(id, nil, nil)
-	return &Transaction{nrTrx}
+	return TrxCtx(context.Background(), id)
+}
+
+// Like Trx, for a goroutine running with the pprof labels of ctx (e.g. within
+// pprof.Do), which End restores instead of clearing the labels
+func TrxCtx(ctx context.Context, id string) *Transaction {
+	trx := &Transaction{trx: tracer.StartTransaction(id), name: id, sampled: sampleTransaction(), profileCtx: ctx}
+	trx.LabelProfile("transaction", id)
+	return trx
 }
 
 func (trx *Transaction) Segment(name string) *Segment {
-	return &Segment{newrelic.StartSegment(trx.nrTrx, name)}
+	seg := &Segment{trx: trx, name: name, start: clock.Now()}
+	if trx.trx != nil {
+		seg.seg = trx.trx.StartSegment(name)
+	}
+	return seg
 }
 
 func (trx *Transaction) NoticeError(name string) {
-	if trx.nrTrx != nil {
-		trx.nrTrx.NoticeError(errors.New(name))
+	trx.noticeError(errors.New(name))
+}
+
+func (trx *Transaction) noticeError(err error) {
+	trx.errored = true
+	if trx.trx != nil {
+		trx.trx.NoticeError(err)
+	}
+}
+
+// Returns the trace and span IDs of the transaction, or empty strings if its
+// backend does not expose them
+func (trx *Transaction) TraceIDs() (traceID string, spanID string) {
+	if traced, ok := trx.trx.(tracedTransaction); ok {
+		return traced.TraceIDs()
 	}
+	return "", ""
 }
 
+// Ends the transaction, discarding it instead if it was not sampled and
+// noticed no errors
 func (trx *Transaction) End() {
-	if trx.nrTrx != nil {
-		trx.nrTrx.End()
+	trx.clearProfileLabels()
+	if trx.trx == nil {
+		return
+	}
+	if discardable, ok := trx.trx.(discardableTransaction); ok && !trx.sampled && !trx.errored {
+		discardable.Discard()
+		return
 	}
+	trx.trx.End()
 }
 
 type Segment struct {
-	nrSeg *newrelic.Segment
+	seg   TracerSegment
+	trx   *Transaction
+	name  string
+	start time.Time
+	attrs Tags
 }
 
 func NullSegment() *Segment {
@@ -180,21 +300,92 @@ func NullSegment() *Segment {
 }
 
 func (seg *Segment) End() {
-	if seg.nrSeg != nil {
-		seg.nrSeg.End()
+	seg.end(nil)
+}
+
+// Attaches a detail of the operation, like a table name or a cache hit, to the
+// backend segment and as a tag of its duration metric
+func (seg *Segment) AddAttribute(key string, value interface{}) {
+	if seg.attrs == nil {
+		seg.attrs = Tags{}
+	}
+	seg.attrs[key] = value
+	if s, ok := seg.seg.(attributeSegment); ok {
+		s.AddAttribute(key, value)
 	}
 }
 
-// Middleware to use with New Relic
-func NewRelic() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		txn := NewRelicApp.StartTransaction(c.Request.URL.String(), c.Writer, c.Request)
-		defer txn.End()
-		c.Set("NR_TXN", txn)
-		c.Next()
+// Ends the segment tagging its duration metric with the outcome: "success", or
+// "error" and the error class. The error is also noticed on the transaction.
+// Since deferred arguments are evaluated at the defer statement, defer it
+// through a closure over a named error result:
+//
+//	defer func() { seg.EndWith(err) }()
+func (seg *Segment) EndWith(err error) {
+	if err == nil {
+		seg.end(Tags{"outcome": "success"})
+		return
+	}
+	if seg.trx != nil {
+		seg.trx.noticeError(err)
+	}
+	seg.end(Tags{"outcome": "error", "error_class": errorClass(err)})
+}
+
+func (seg *Segment) end(tags Tags) {
+	if seg.seg != nil {
+		seg.seg.End()
+	}
+	if segmentDurations && pushEnabled && seg.trx != nil {
+		metric := Metric{FULL, "segment." + seg.name + ".ms", ElapsedMilliseconds(seg.start), nil, UNIT_MS}
+		if err := PushMetric(metric, nil, Tags{"transaction": seg.trx.name}, seg.attrs, tags); err != nil {
+			errorLogger(err)
+		}
 	}
 }
 
+var errorClass = func(err error) string {
+	return "unknown"
+}
+
+// Sets the function used to tag segments ended with an error. The log package
+// installs log.ClassifyError.
+func SetErrorClassifier(classifier func(err error) string) {
+	errorClass = func(err error) string {
+		if class := classifier(err); class != "" {
+			return class
+		}
+		return "unknown"
+	}
+}
+
+var segmentDurations = false
+
+// When enabled, every Segment.End also pushes a FULL metric
+// "segment.<name>.ms" tagged with the transaction name, as long as pushing is
+// enabled with EnablePush
+func RecordSegmentDurations(enabled bool) {
+	segmentDurations = enabled
+}
+
+var pushEnabled = false
+
+// Enables the metrics the package pushes on its own, like segment durations.
+// log.ConfigurePushMetrics enables it.
+func EnablePush(enabled bool) {
+	pushEnabled = enabled
+}
+
+var errorLogger = func(err error) {
+	stderr.Logf("error", "Error pushing metric: %s", err)
+}
+
+// Sets the function reporting the errors of the metrics the package pushes on
+// its own. The log package installs one logging them as errors.
+func SetErrorLogger(logger func(err error)) {
+	errorLogger = logger
+}
+
 // Datatype to hanlde metric tags
 func (tags Tags) asMetricTags() []string {
 	res := make([]string, 0, len(tags))
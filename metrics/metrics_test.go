@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSegmentDurations(t *testing.T) {
+	b := useRecordingBackend(t)
+	RecordSegmentDurations(true)
+	defer RecordSegmentDurations(false)
+
+	trx := Trx("checkout")
+	trx.Segment("disabled push").End()
+	if recorded := b.recorded(); len(recorded) != 0 {
+		t.Errorf("Pushed with push disabled: %v", recorded)
+	}
+
+	EnablePush(true)
+	defer EnablePush(false)
+	seg := trx.Segment("db")
+	seg.AddAttribute("table", "users")
+	seg.EndWith(errors.New("boom"))
+	recorded := b.recorded()
+	if len(recorded) != 1 || !strings.HasPrefix(recorded[0], "F .segment.db.ms ") ||
+		!strings.Contains(recorded[0], "outcome:error") || !strings.Contains(recorded[0], "table:users") || !strings.Contains(recorded[0], "transaction:checkout") {
+		t.Errorf("Unexpected metrics %v", recorded)
+	}
+
+	var reported error
+	defer SetErrorLogger(errorLogger)
+	SetErrorLogger(func(err error) { reported = err })
+	invalid := trx.Segment("invalid")
+	invalid.AddAttribute("", "empty key")
+	invalid.End()
+	if reported == nil {
+		t.Error("Push error not reported")
+	}
+}
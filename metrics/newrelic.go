@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	newrelic "github.com/newrelic/go-agent"
+)
+
+var NewRelicApp newrelic.Application
+
+func InitNewRelic(debug bool, environment string, appName string, appKey string) error {
+	config := newrelic.NewConfig(fmt.Sprintf("%s.%s", environment, appName), appKey)
+	if debug {
+		config.Logger = newrelic.NewDebugLogger(os.Stdout)
+	}
+	if app, err := newrelic.NewApplication(config); err != nil {
+		return fmt.Errorf("Could not create newrelic agent: %s", err)
+	} else {
+		NewRelicApp = app
+	}
+	UseTracer(newRelicTracer{})
+	return nil
+}
+
+// Middleware to use with New Relic
+func NewRelic() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		txn := NewRelicApp.StartTransaction(c.Request.URL.String(), c.Writer, c.Request)
+		defer txn.End()
+		c.Set("NR_TXN", txn)
+		c.Next()
+	}
+}
+
+type newRelicTracer struct{}
+
+func (newRelicTracer) StartTransaction(name string) TracerTransaction {
+	return newRelicTransaction{NewRelicApp.StartTransaction(name, nil, nil)}
+}
+
+func (newRelicTracer) Middleware() gin.HandlerFunc {
+	return NewRelic()
+}
+
+type newRelicTransaction struct {
+	txn newrelic.Transaction
+}
+
+func (trx newRelicTransaction) StartSegment(name string) TracerSegment {
+	return newRelicSegment{newrelic.StartSegment(trx.txn, name), trx.txn}
+}
+
+func (trx newRelicTransaction) NoticeError(err error) {
+	trx.txn.NoticeError(err)
+}
+
+func (trx newRelicTransaction) TraceIDs() (string, string) {
+	metadata := trx.txn.GetTraceMetadata()
+	return metadata.TraceID, metadata.SpanID
+}
+
+func (trx newRelicTransaction) End() {
+	trx.txn.End()
+}
+
+func (trx newRelicTransaction) Discard() {
+	trx.txn.Ignore()
+	trx.txn.End()
+}
+
+type newRelicSegment struct {
+	seg *newrelic.Segment
+	txn newrelic.Transaction
+}
+
+func (seg newRelicSegment) End() {
+	seg.seg.End()
+}
+
+// The agent has no segment attributes, so they are added to the transaction
+// prefixed with the segment name
+func (seg newRelicSegment) AddAttribute(key string, value interface{}) {
+	seg.txn.AddAttribute(seg.seg.Name+"."+key, value)
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var OtelTracer trace.Tracer
+
+// Selects OpenTelemetry as the transactions backend. Spans are exported by the
+// globally registered TracerProvider, so the exporter (OTLP, Jaeger, ...) is
+// configured by the application with otel.SetTracerProvider.
+func InitOpenTelemetry(appName string) {
+	OtelTracer = otel.Tracer(appName)
+	UseTracer(otelTracer{})
+}
+
+// Middleware to use with OpenTelemetry. Incoming trace context headers are honored
+// and the request context carries the server span.
+func OpenTelemetry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := ExtractTraceContext(c.Request.Context(), c.Request.Header)
+		ctx, span := OtelTracer.Start(ctx, c.Request.Method+" "+c.Request.URL.Path, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+	}
+}
+
+// Returns the context carrying the span of the transaction, to propagate it to
+// outgoing calls. Transactions of other backends return context.Background().
+func (trx *Transaction) Context() context.Context {
+	if t, ok := trx.trx.(otelTransaction); ok {
+		return t.ctx
+	}
+	return context.Background()
+}
+
+// Writes the trace context found in ctx into the headers of an outgoing request
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Returns a copy of ctx carrying the trace context found in the headers of an incoming request
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Returns the members of the OpenTelemetry baggage carried by ctx
+func Baggage(ctx context.Context) map[string]string {
+	members := baggage.FromContext(ctx).Members()
+	values := make(map[string]string, len(members))
+	for _, member := range members {
+		values[member.Key()] = member.Value()
+	}
+	return values
+}
+
+func otelTraceIDs(ctx context.Context) (string, string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID().String(), sc.SpanID().String()
+}
+
+type otelTracer struct{}
+
+func (otelTracer) StartTransaction(name string) TracerTransaction {
+	ctx, span := OtelTracer.Start(context.Background(), name)
+	return otelTransaction{ctx, span}
+}
+
+func (otelTracer) Middleware() gin.HandlerFunc {
+	return OpenTelemetry()
+}
+
+type otelTransaction struct {
+	ctx  context.Context
+	span trace.Span
+}
+
+func (trx otelTransaction) StartSegment(name string) TracerSegment {
+	_, span := OtelTracer.Start(trx.ctx, name)
+	return otelSegment{span}
+}
+
+func (trx otelTransaction) NoticeError(err error) {
+	trx.span.RecordError(err)
+	trx.span.SetStatus(codes.Error, err.Error())
+}
+
+func (trx otelTransaction) TraceIDs() (string, string) {
+	return otelTraceIDs(trx.ctx)
+}
+
+func (trx otelTransaction) End() {
+	trx.span.End()
+}
+
+type otelSegment struct {
+	span trace.Span
+}
+
+func (seg otelSegment) End() {
+	seg.span.End()
+}
+
+func (seg otelSegment) AddAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		seg.span.SetAttributes(attribute.String(key, v))
+	case bool:
+		seg.span.SetAttributes(attribute.Bool(key, v))
+	case int:
+		seg.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		seg.span.SetAttributes(attribute.Int64(key, v))
+	case float64:
+		seg.span.SetAttributes(attribute.Float64(key, v))
+	default:
+		seg.span.SetAttributes(attribute.String(key, fmt.Sprintf("%v", v)))
+	}
+}
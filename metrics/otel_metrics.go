@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Backend recording metrics as OpenTelemetry instruments of the global meter
+// provider: SIMPLE metrics as counters, FULL and COMPOUND ones as histograms
+type otelMetricsBackend struct {
+	meter      metric.Meter
+	lock       sync.Mutex
+	counters   map[string]metric.Float64Counter
+	histograms map[string]metric.Float64Histogram
+}
+
+// Returns a backend for UseBackend recording metrics with OpenTelemetry
+func OtelMetricsBackend(meterName string) UnitBackend {
+	return &otelMetricsBackend{
+		meter:      otel.Meter(meterName),
+		counters:   map[string]metric.Float64Counter{},
+		histograms: map[string]metric.Float64Histogram{},
+	}
+}
+
+func (b *otelMetricsBackend) Record(metricType string, name string, value float64, tags []string) error {
+	return b.RecordUnit(metricType, name, value, UNIT_NONE, tags)
+}
+
+func (b *otelMetricsBackend) RecordUnit(metricType string, name string, value float64, unit Unit, tags []string) error {
+	options := metric.WithAttributes(otelAttributes(tags)...)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch metricType {
+	case SIMPLE:
+		counter, ok := b.counters[name]
+		if !ok {
+			var err error
+			if counter, err = b.meter.Float64Counter(name, metric.WithUnit(ucum(unit))); err != nil {
+				return err
+			}
+			b.counters[name] = counter
+		}
+		counter.Add(context.Background(), value, options)
+	case FULL, COMPOUND:
+		histogram, ok := b.histograms[name]
+		if !ok {
+			var err error
+			if histogram, err = b.meter.Float64Histogram(name, metric.WithUnit(ucum(unit))); err != nil {
+				return err
+			}
+			b.histograms[name] = histogram
+		}
+		histogram.Record(context.Background(), value, options)
+	default:
+		return fmt.Errorf("Unkown metric type: %s", metricType)
+	}
+	return nil
+}
+
+// Returns the UCUM code OpenTelemetry expects for unit
+func ucum(unit Unit) string {
+	switch unit {
+	case UNIT_BYTES:
+		return "By"
+	case UNIT_PERCENT:
+		return "%"
+	case UNIT_COUNT:
+		return "1"
+	}
+	return string(unit)
+}
+
+func otelAttributes(tags []string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(tags))
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, ":", 2)
+		if len(kv) == 2 {
+			attrs = append(attrs, attribute.String(kv[0], kv[1]))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+var profileLabels = false
+
+// When enabled, Trx sets the pprof label "transaction" on the calling goroutine
+// and End removes it, so CPU profiles can be sliced by transaction name. The
+// transaction must end on the goroutine that started it. Labels set by the
+// caller are kept only if given to TrxCtx, since pprof does not expose them.
+func LabelProfiles(enabled bool) {
+	profileLabels = enabled
+}
+
+// Adds a pprof label to the goroutine running the transaction, when LabelProfiles is enabled
+func (trx *Transaction) LabelProfile(key string, value string) {
+	if !profileLabels || value == "" {
+		return
+	}
+	trx.labels = append(trx.labels, key, value)
+	pprof.SetGoroutineLabels(pprof.WithLabels(trx.profileContext(), pprof.Labels(trx.labels...)))
+}
+
+// Restores the labels the goroutine had before the transaction
+func (trx *Transaction) clearProfileLabels() {
+	if trx.labels != nil {
+		pprof.SetGoroutineLabels(trx.profileContext())
+		trx.labels = nil
+	}
+}
+
+func (trx *Transaction) profileContext() context.Context {
+	if trx.profileCtx == nil {
+		return context.Background()
+	}
+	return trx.profileCtx
+}
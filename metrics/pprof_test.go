@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+// Returns the labels of the goroutine profile, which lists them per stack
+func goroutineLabels() string {
+	buf := new(bytes.Buffer)
+	pprof.Lookup("goroutine").WriteTo(buf, 1)
+	var labels []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "# labels: ") {
+			labels = append(labels, strings.TrimPrefix(line, "# labels: "))
+		}
+	}
+	return strings.Join(labels, " ")
+}
+
+func TestTransactionRestoresProfileLabels(t *testing.T) {
+	LabelProfiles(true)
+	defer LabelProfiles(false)
+	pprof.Do(context.Background(), pprof.Labels("job", "sync"), func(ctx context.Context) {
+		trx := TrxCtx(ctx, "import")
+		if labels := goroutineLabels(); !strings.Contains(labels, `"job":"sync"`) || !strings.Contains(labels, `"transaction":"import"`) {
+			t.Errorf("Expected the job and transaction labels, got %s", labels)
+		}
+		trx.End()
+		if labels := goroutineLabels(); !strings.Contains(labels, `"job":"sync"`) || strings.Contains(labels, "transaction") {
+			t.Errorf("Expected the job label alone, got %s", labels)
+		}
+	})
+}
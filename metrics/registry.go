@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metric declared once in the registry. Names ending in ".*" match any suffix.
+type Definition struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Tags        []string `json:"tags,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Unit        Unit     `json:"unit,omitempty"`
+}
+
+// Customizes a Definition when registering it
+type Option func(def *Definition)
+
+// Declares the tag keys the metric is pushed with
+func WithTags(keys ...string) Option {
+	return func(def *Definition) {
+		def.Tags = append(def.Tags, keys...)
+	}
+}
+
+// Documents what the metric measures in the exported catalog
+func WithDescription(description string) Option {
+	return func(def *Definition) {
+		def.Description = description
+	}
+}
+
+// Sets the unit of the metric values
+func WithUnit(unit Unit) Option {
+	return func(def *Definition) {
+		def.Unit = unit
+	}
+}
+
+var registry = map[string]*Definition{}
+var registryLock sync.RWMutex
+var rejectUnregistered = false
+
+func init() {
+	MustRegister("runtime.goroutines", FULL, WithDescription("Number of goroutines"), WithUnit(UNIT_COUNT))
+	MustRegister("runtime.heap_alloc_bytes", FULL, WithDescription("Bytes of allocated heap objects"), WithUnit(UNIT_BYTES))
+	MustRegister("runtime.gc_pause_ms", FULL, WithDescription("GC pause time since the previous sample"), WithUnit(UNIT_MS))
+	MustRegister("segment.*", FULL, WithTags("transaction", "outcome", "error_class"),
+		WithDescription("Duration of a transaction segment, see RecordSegmentDurations"), WithUnit(UNIT_MS))
+}
+
+// Declares a metric, failing if the name is already registered with a
+// different definition
+func Register(name string, metricType string, options ...Option) (*Handle, error) {
+	def := &Definition{Name: name, Type: metricType}
+	for _, option := range options {
+		option(def)
+	}
+	if err := validateMetric(name, Metric{metricType, name, 0, nil, ""}, nil); err != nil {
+		return nil, err
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if existing, ok := registry[name]; ok {
+		if !existing.equal(def) {
+			return nil, fmt.Errorf("Metric %s already registered", name)
+		}
+		return &Handle{existing}, nil
+	}
+	registry[name] = def
+	return &Handle{def}, nil
+}
+
+func (def *Definition) equal(other *Definition) bool {
+	if def.Type != other.Type || def.Description != other.Description || def.Unit != other.Unit || len(def.Tags) != len(other.Tags) {
+		return false
+	}
+	for i, tag := range def.Tags {
+		if other.Tags[i] != tag {
+			return false
+		}
+	}
+	return true
+}
+
+// Like Register, but panics on error, for package level declarations
+func MustRegister(name string, metricType string, options ...Option) *Handle {
+	handle, err := Register(name, metricType, options...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return handle
+}
+
+// Declares a SIMPLE metric, e.g.
+//
+//	var OrdersCreated = metrics.MustRegisterCounter("orders.created", metrics.WithTags("channel"))
+//	OrdersCreated.Inc(metrics.Tags{"channel": "web"})
+func MustRegisterCounter(name string, options ...Option) *Handle {
+	return MustRegister(name, SIMPLE, options...)
+}
+
+// Declares a FULL metric
+func MustRegisterFull(name string, options ...Option) *Handle {
+	return MustRegister(name, FULL, options...)
+}
+
+// Declares a COMPOUND metric
+func MustRegisterCompound(name string, options ...Option) *Handle {
+	return MustRegister(name, COMPOUND, options...)
+}
+
+// When enabled, PushMetric rejects metrics whose name is not registered or whose
+// type differs from the registered one
+func RejectUnregistered(enabled bool) {
+	rejectUnregistered = enabled
+}
+
+// Returns the registered definitions sorted by name
+func Definitions() []Definition {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	defs := make([]Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, *def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Returns the definition matching name, exactly or through a ".*" wildcard
+func lookupDefinition(name string) *Definition {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	if def, ok := registry[name]; ok {
+		return def
+	}
+	for pattern, def := range registry {
+		if strings.HasSuffix(pattern, ".*") && strings.HasPrefix(name, pattern[:len(pattern)-1]) {
+			return def
+		}
+	}
+	return nil
+}
+
+func checkRegistered(metric Metric) error {
+	if !rejectUnregistered {
+		return nil
+	}
+	def := lookupDefinition(metric.Name)
+	if def == nil {
+		return fmt.Errorf("Metric %s is not registered", metric.Name)
+	}
+	if def.Type != metric.metricType && !(def.Type == SIMPLE && metric.metricType == ERROR) {
+		return fmt.Errorf("Metric %s is registered as %s, pushed as %s", metric.Name, def.Type, metric.metricType)
+	}
+	return nil
+}
+
+// Registered metric, pushed with its declared name and type
+type Handle struct {
+	def *Definition
+}
+
+func (handle *Handle) Definition() Definition {
+	return *handle.def
+}
+
+// Returns the metric with value, to be passed to the logging functions
+func (handle *Handle) Metrics(value float64, tags ...Tags) Metrics {
+	return Metrics{[]Metric{{handle.def.Type, handle.def.Name, value, mergeTags(tags), handle.def.Unit}}}
+}
+
+// Pushes the metric with value, failing if tags has keys not declared with WithTags
+func (handle *Handle) Record(value float64, tags ...Tags) error {
+	merged := mergeTags(tags)
+	if err := handle.checkTags(merged); err != nil {
+		return err
+	}
+	return PushMetric(Metric{handle.def.Type, handle.def.Name, value, merged, handle.def.Unit}, nil)
+}
+
+// Pushes the metric with a value of 1
+func (handle *Handle) Inc(tags ...Tags) error {
+	return handle.Record(1, tags...)
+}
+
+func (handle *Handle) checkTags(tags Tags) error {
+	for k := range tags {
+		declared := false
+		for _, key := range handle.def.Tags {
+			declared = declared || key == k
+		}
+		if !declared {
+			return fmt.Errorf("Tag %s is not declared for metric %s", k, handle.def.Name)
+		}
+	}
+	return nil
+}
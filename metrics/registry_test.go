@@ -0,0 +1,31 @@
+package metrics
+
+import "testing"
+
+func TestRegisterDuplicates(t *testing.T) {
+	defer func() {
+		registryLock.Lock()
+		delete(registry, "test.duplicated")
+		registryLock.Unlock()
+	}()
+	first, err := Register("test.duplicated", SIMPLE, WithTags("a", "b"), WithDescription("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := Register("test.duplicated", SIMPLE, WithTags("a", "b"), WithDescription("d"))
+	if err != nil || same.def != first.def {
+		t.Errorf("Identical definition rejected: %v", err)
+	}
+	for name, options := range map[string][]Option{
+		"other tags":        {WithTags("a"), WithDescription("d")},
+		"other description": {WithTags("a", "b")},
+		"other unit":        {WithTags("a", "b"), WithDescription("d"), WithUnit(UNIT_MS)},
+	} {
+		if _, err := Register("test.duplicated", SIMPLE, options...); err == nil {
+			t.Errorf("Conflicting definition with %s accepted", name)
+		}
+	}
+	if _, err := Register("test.duplicated", FULL, WithTags("a", "b"), WithDescription("d")); err == nil {
+		t.Error("Conflicting type accepted")
+	}
+}
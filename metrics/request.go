@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type requestTagsKey struct{}
+
+// Endpoint of the requests matching no route
+const unmatchedEndpoint = "unmatched"
+
+// Middleware storing the "endpoint" (the route, e.g. "/orders/:id"), "method"
+// and "status" tags of the request in its context, so the metrics pushed by the
+// handlers with PushMetricCtx or the log Ctx-variant functions carry them. The
+// status is only tagged once the header is written. Included by GingonicHandlers.
+func RequestTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			endpoint = unmatchedEndpoint
+		}
+		writer := c.Writer
+		status := func() int {
+			if !writer.Written() {
+				return 0
+			}
+			return writer.Status()
+		}
+		c.Request = c.Request.WithContext(withRequestTags(c.Request.Context(), endpoint, c.Request.Method, status))
+		c.Next()
+	}
+}
+
+// Like RequestTags, for net/http handlers. The endpoint is given since
+// http.ServeMux does not expose the matched pattern:
+//
+//	mux.Handle("/orders/", metrics.TagRequests("/orders", ordersHandler))
+func TagRequests(endpoint string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r.WithContext(withRequestTags(r.Context(), endpoint, r.Method, recorder.Status)))
+	})
+}
+
+// Status is read when a metric is pushed, and is 0 until the header is written,
+// so the metrics pushed before carry no "status" tag
+func withRequestTags(ctx context.Context, endpoint string, method string, status func() int) context.Context {
+	return context.WithValue(ctx, requestTagsKey{}, func() Tags {
+		tags := Tags{"endpoint": endpoint, "method": method}
+		if status := status(); status != 0 {
+			tags["status"] = strconv.Itoa(status)
+		}
+		return tags
+	})
+}
+
+// Returns the tags of the request handled with ctx, or nil outside the
+// RequestTags and TagRequests middlewares
+func RequestTagsFrom(ctx context.Context) Tags {
+	if ctx == nil {
+		return nil
+	}
+	if tags, ok := ctx.Value(requestTagsKey{}).(func() Tags); ok {
+		return tags()
+	}
+	return nil
+}
+
+// Like PushMetric, adding the tags of the request handled with ctx. The tags of
+// the metric and the given ones take precedence.
+func PushMetricCtx(ctx context.Context, metric Metric, trx *Transaction, tags ...Tags) error {
+	if requestTags := RequestTagsFrom(ctx); requestTags != nil {
+		tags = append([]Tags{requestTags, metric.tags}, tags...)
+	}
+	return PushMetric(metric, trx, tags...)
+}
+
+// Records the status of the header written, forwarding http.Flusher and
+// http.Hijacker to the wrapped writer
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		if r.status == 0 {
+			r.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", r.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+// Returns the wrapped writer, for http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Returns the status of the header written, or 0 before
+func (r *statusRecorder) Status() int {
+	return r.status
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestTags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestTags())
+	handler := func(c *gin.Context) {
+		PushMetricCtx(c.Request.Context(), Counter("orders.received").Values[0], nil)
+		c.Status(http.StatusCreated)
+		PushMetricCtx(c.Request.Context(), Counter("orders.received").Values[0], nil)
+		c.Writer.WriteHeaderNow()
+		PushMetricCtx(c.Request.Context(), Counter("orders.created").Values[0], nil)
+		PushMetricCtx(c.Request.Context(), Counter("orders.created", Tags{"status": "override"}).Values[0], nil)
+	}
+	engine.POST("/orders/:id", handler)
+	engine.NoRoute(handler)
+	mux := http.NewServeMux()
+	mux.Handle("/orders/", TagRequests("/orders", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		PushMetricCtx(r.Context(), Counter("orders.received").Values[0], nil)
+		w.WriteHeader(http.StatusAccepted)
+		PushMetricCtx(r.Context(), Counter("orders.created").Values[0], nil)
+		PushMetricCtx(r.Context(), Counter("orders.created").Values[0], nil, Tags{"status": "override"})
+	})))
+
+	cases := []struct {
+		name     string
+		handler  http.Handler
+		path     string
+		expected []string
+	}{
+		{"gin route", engine, "/orders/42", []string{
+			"S .orders.created 1 endpoint:/orders/:id,method:POST,status:201",
+			"S .orders.created 1 endpoint:/orders/:id,method:POST,status:override",
+			"S .orders.received 1 endpoint:/orders/:id,method:POST",
+			"S .orders.received 1 endpoint:/orders/:id,method:POST"}},
+		{"gin unmatched", engine, "/other", []string{
+			"S .orders.created 1 endpoint:unmatched,method:POST,status:201",
+			"S .orders.created 1 endpoint:unmatched,method:POST,status:override",
+			"S .orders.received 1 endpoint:unmatched,method:POST",
+			"S .orders.received 1 endpoint:unmatched,method:POST"}},
+		{"net/http", mux, "/orders/42", []string{
+			"S .orders.created 1 endpoint:/orders,method:POST,status:202",
+			"S .orders.created 1 endpoint:/orders,method:POST,status:override",
+			"S .orders.received 1 endpoint:/orders,method:POST"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			backend := useRecordingBackend(t)
+			c.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, c.path, nil))
+			recorded := backend.recorded()
+			if len(recorded) != len(c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, recorded)
+			}
+			for i := range recorded {
+				if recorded[i] != c.expected[i] {
+					t.Errorf("Expected %s, got %s", c.expected[i], recorded[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRequestTagsFromOutsideRequests(t *testing.T) {
+	if tags := RequestTagsFrom(httptest.NewRequest(http.MethodGet, "/", nil).Context()); tags != nil {
+		t.Errorf("Expected no tags, got %v", tags)
+	}
+}
+
+func TestTagRequestsWriter(t *testing.T) {
+	var status func() int
+	handler := TagRequests("/events", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status = func() int { return w.(*statusRecorder).Status() }
+		if status() != 0 {
+			t.Errorf("Expected no status before writing, got %d", status())
+		}
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			t.Errorf("Expected an error hijacking a writer that cannot be hijacked")
+		}
+	}))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if !response.Flushed {
+		t.Errorf("Expected the response flushed")
+	}
+	if status() != http.StatusOK {
+		t.Errorf("Expected the status of a write without header to be 200, got %d", status())
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+)
+
+var collectors = map[*collector]bool{}
+var collectorsLock sync.Mutex
+
+type collector struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+// Pushes the number of goroutines, heap usage and GC pause time every interval
+// until the returned function (or Shutdown) stops it
+func CollectRuntime(interval time.Duration) func() {
+	c := &collector{stop: make(chan struct{})}
+	collectorsLock.Lock()
+	collectors[c] = true
+	collectorsLock.Unlock()
+	go c.run(interval)
+	return c.close
+}
+
+func (c *collector) run(interval time.Duration) {
+	var lastPause uint64
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-clock.After(interval):
+		}
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		PushMetric(Metric{FULL, "runtime.goroutines", float64(runtime.NumGoroutine()), nil, UNIT_COUNT}, nil)
+		PushMetric(Metric{FULL, "runtime.heap_alloc_bytes", float64(stats.HeapAlloc), nil, UNIT_BYTES}, nil)
+		PushMetric(Metric{FULL, "runtime.gc_pause_ms", float64(stats.PauseTotalNs-lastPause) / 1e6, nil, UNIT_MS}, nil)
+		lastPause = stats.PauseTotalNs
+	}
+}
+
+func (c *collector) close() {
+	c.once.Do(func() {
+		close(c.stop)
+		collectorsLock.Lock()
+		delete(collectors, c)
+		collectorsLock.Unlock()
+	})
+}
+
+// Stops every collector started with CollectRuntime
+func stopCollectors() {
+	collectorsLock.Lock()
+	running := make([]*collector, 0, len(collectors))
+	for c := range collectors {
+		running = append(running, c)
+	}
+	collectorsLock.Unlock()
+	for _, c := range running {
+		c.close()
+	}
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gonzalo-mangado/logging/internal/stderr"
+)
+
+var trxSampling uint64 = 1
+var trxCounter uint64
+
+func init() {
+	if value := os.Getenv("METRICS_TRX_SAMPLING"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			stderr.Logf("error", "Invalid METRICS_TRX_SAMPLING %q: must be a positive integer", value)
+			return
+		}
+		SampleTransactions(n)
+	}
+}
+
+// Records only 1 in n transactions started with Trx; the rest are discarded
+// when they end, unless they noticed an error. Also set by the
+// METRICS_TRX_SAMPLING env var. Transactions started by the tracer middlewares
+// and backends that cannot discard transactions (OpenTelemetry) are not affected.
+func SampleTransactions(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreUint64(&trxSampling, uint64(n))
+}
+
+func sampleTransaction() bool {
+	return (atomic.AddUint64(&trxCounter, 1)-1)%atomic.LoadUint64(&trxSampling) == 0
+}
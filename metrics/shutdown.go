@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+// Stops the collectors, pushes the aggregated counters, replays the dead
+// letters and waits for the tracer agents to send their pending data, all
+// within timeout. Only the Honeycomb client created by InitHoneycomb is closed.
+// Returns an error reporting the metrics that could not be sent.
+func Shutdown(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	abort := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(abort) })
+	defer timer.Stop()
+
+	stopCollectors()
+	AggregateCounters(0)
+	ReplayDeadLetters()
+	if NewRelicApp != nil {
+		if remaining := time.Until(deadline); remaining > 0 {
+			NewRelicApp.Shutdown(remaining)
+		}
+	}
+	if ElasticTracer != nil {
+		ElasticTracer.Flush(abort)
+	}
+	if ownedHoneycombClient != nil {
+		closed := make(chan struct{})
+		go func(client *libhoney.Client) {
+			client.Close()
+			close(closed)
+		}(ownedHoneycombClient)
+		ownedHoneycombClient = nil
+		select {
+		case <-closed:
+		case <-abort:
+		}
+	}
+
+	if pending, dropped := DeadLetters(); pending > 0 || dropped > 0 {
+		return fmt.Errorf("%d metrics could not be sent and %d were dropped", pending, dropped)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Returns the live configuration of the metrics package
+func Status() Tags {
+	aggregatorLock.RLock()
+	aggregating := aggregator != nil
+	aggregatorLock.RUnlock()
+	tracerType := "none"
+	if _, ok := tracer.(nullTracer); !ok {
+		tracerType = fmt.Sprintf("%T", tracer)
+	}
+	pending, dropped := DeadLetters()
+	return Tags{
+		"backend":              fmt.Sprintf("%T", backend),
+		"dead_letters":         pending,
+		"dead_letters_dropped": dropped,
+		"prefix":               namePrefix,
+		"default_tags":         defaultTags,
+		"tracer":               tracerType,
+		"dry_run":              dryRun,
+		"trx_sampling":         atomic.LoadUint64(&trxSampling),
+		"aggregated_counters":  aggregating,
+		"segment_durations":    segmentDurations,
+		"push_enabled":         pushEnabled,
+	}
+}
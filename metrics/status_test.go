@@ -0,0 +1,11 @@
+package metrics
+
+import "testing"
+
+func TestStatus(t *testing.T) {
+	useRecordingBackend(t)
+	status := Status()
+	if status["backend"] != "*metrics.recordingBackend" || status["tracer"] != "none" {
+		t.Errorf("Unexpected backend %v and tracer %v", status["backend"], status["tracer"])
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/gonzalo-mangado/logging/clock"
+	"github.com/gonzalo-mangado/logging/format"
+)
+
+// Times a multi-phase operation. Each lap pushes "<name>.lap" tagged with the
+// lap name, and Stop pushes "<name>" with the overall duration, both in ms.
+//
+//	sw := metrics.StartStopwatch("import", metrics.Tags{"source": "s3"})
+//	fetch()
+//	sw.Lap("fetch")
+//	transform()
+//	sw.Lap("transform")
+//	store()
+//	sw.Lap("store")
+//	sw.Stop()
+type Stopwatch struct {
+	name  string
+	tags  Tags
+	start time.Time
+	lap   time.Time
+	laps  map[string]time.Duration
+}
+
+// Returns a started stopwatch
+func StartStopwatch(name string, tags ...Tags) *Stopwatch {
+	sw := &Stopwatch{name: name, tags: mergeTags(tags)}
+	sw.Start()
+	return sw
+}
+
+// Restarts the stopwatch, discarding previous laps
+func (sw *Stopwatch) Start() {
+	sw.start = clock.Now()
+	sw.lap = sw.start
+	sw.laps = map[string]time.Duration{}
+}
+
+// Ends the current lap, pushes its duration and returns it
+func (sw *Stopwatch) Lap(name string) time.Duration {
+	now := clock.Now()
+	elapsed := now.Sub(sw.lap)
+	sw.lap = now
+	sw.laps[name] += elapsed
+	PushMetric(Metric{FULL, sw.name + ".lap", format.Milliseconds(elapsed), nil, UNIT_MS}, nil, sw.tags, Tags{"lap": name})
+	return elapsed
+}
+
+// Pushes the overall duration and returns it
+func (sw *Stopwatch) Stop() time.Duration {
+	elapsed := clock.Since(sw.start)
+	PushMetric(Metric{FULL, sw.name, format.Milliseconds(elapsed), nil, UNIT_MS}, nil, sw.tags)
+	return elapsed
+}
+
+// Returns the accumulated duration of each lap name
+func (sw *Stopwatch) Laps() map[string]time.Duration {
+	return sw.laps
+}
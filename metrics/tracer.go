@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Backend used to record transactions and segments (New Relic, Elastic APM, ...)
+type Tracer interface {
+	StartTransaction(name string) TracerTransaction
+	Middleware() gin.HandlerFunc
+}
+
+// Transaction as seen by a Tracer backend
+type TracerTransaction interface {
+	StartSegment(name string) TracerSegment
+	NoticeError(err error)
+	End()
+}
+
+// Segment as seen by a Tracer backend
+type TracerSegment interface {
+	End()
+}
+
+// Implemented by transactions whose backend can end them without recording them
+type discardableTransaction interface {
+	Discard()
+}
+
+// Implemented by transactions whose backend exposes their trace context
+type tracedTransaction interface {
+	TraceIDs() (traceID string, spanID string)
+}
+
+// Implemented by segments whose backend supports attributes
+type attributeSegment interface {
+	AddAttribute(key string, value interface{})
+}
+
+var tracer Tracer = nullTracer{}
+
+// Selects the backend used by Trx and GingonicHandlers
+func UseTracer(t Tracer) {
+	if t == nil {
+		t = nullTracer{}
+	}
+	tracer = t
+}
+
+// Tracer that records nothing, used until a backend is initialized
+type nullTracer struct{}
+
+func (nullTracer) StartTransaction(name string) TracerTransaction {
+	return nil
+}
+
+func (nullTracer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+	}
+}
+
+// Returns the trace and span IDs of the OpenTelemetry span or Elastic APM
+// transaction carried by ctx, or empty strings if there is none
+func TraceIDs(ctx context.Context) (traceID string, spanID string) {
+	if traceID, spanID = otelTraceIDs(ctx); traceID != "" {
+		return traceID, spanID
+	}
+	return elasticTraceIDs(ctx)
+}
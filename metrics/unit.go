@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gonzalo-mangado/logging/format"
+)
+
+// Unit of the values of a metric, forwarded to the backends that support it
+type Unit string
+
+const (
+	UNIT_NONE    Unit = ""
+	UNIT_MS      Unit = "ms"
+	UNIT_BYTES   Unit = "bytes"
+	UNIT_PERCENT Unit = "percent"
+	UNIT_COUNT   Unit = "count"
+)
+
+// Returns value rendered for people: "350ms", "4.2 MiB", "12.5%"
+func (unit Unit) Format(value float64) string {
+	switch unit {
+	case UNIT_MS:
+		return format.Duration(time.Duration(value * float64(time.Millisecond)))
+	case UNIT_BYTES:
+		return format.Bytes(int64(value))
+	case UNIT_PERCENT:
+		return strconv.FormatFloat(value, 'f', 1, 64) + "%"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}